@@ -0,0 +1,117 @@
+package brickspec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// DecodeSpec strictly decodes the JSON spec of a brick into the given pointer:
+// unknown fields are rejected, then the defaults are applied and the spec is validated.
+// All the errors are returned at once, as FieldErrors relative to the spec.
+func DecodeSpec(data []byte, spec any) error {
+	if len(bytes.TrimSpace(data)) == 0 || bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		data = []byte("{}")
+	}
+
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Errorf("", "invalid JSON: %v", err)
+	}
+
+	specType := reflect.TypeOf(spec)
+	if specType.Kind() != reflect.Pointer {
+		return fmt.Errorf("spec must be a pointer, got %s", specType)
+	}
+	if errs := unknownFields(raw, specType.Elem(), ""); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if err := json.Unmarshal(data, spec); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return Errorf(typeErr.Field, "cannot use a JSON %s as %s", typeErr.Value, typeErr.Type)
+		}
+		return Errorf("", "%v", err)
+	}
+
+	if err := ApplyDefaults(spec); err != nil {
+		return err
+	}
+	return Validate(spec)
+}
+
+// unknownFields returns an error for each JSON field which doesn't match a field of the given type.
+// Just like encoding/json, field names are matched case-insensitively,
+// so that the blueprints written with another case - such as the former PascalCase fields - are still accepted.
+func unknownFields(raw any, t reflect.Type, path string) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var errs []error
+	switch value := raw.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct:
+			for _, key := range slices.Sorted(maps.Keys(value)) {
+				fieldValue := value[key]
+				field, ok := lookupField(t, key)
+				if !ok {
+					errs = append(errs, Errorf(joinPath(path, key), "unknown field"))
+					continue
+				}
+				errs = append(errs, unknownFields(fieldValue, field.Type, joinPath(path, jsonName(field)))...)
+			}
+		case reflect.Map:
+			for _, key := range slices.Sorted(maps.Keys(value)) {
+				errs = append(errs, unknownFields(value[key], t.Elem(), joinPath(path, key))...)
+			}
+		}
+	case []any:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, elem := range value {
+				errs = append(errs, unknownFields(elem, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return errs
+}
+
+// lookupField returns the struct field matching the given JSON key - or else matching it case-insensitively
+func lookupField(t reflect.Type, key string) (reflect.StructField, bool) {
+	var fallback *reflect.StructField
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+		if name == key {
+			return field, true
+		}
+		if fallback == nil && strings.EqualFold(name, key) {
+			fallback = &field
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return reflect.StructField{}, false
+}
+
+// jsonName returns the name of the field in JSON
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
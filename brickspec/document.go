@@ -0,0 +1,286 @@
+package brickspec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Position is a position in a blueprint file
+type Position struct {
+	Line   int
+	Column int
+}
+
+// document is a brick of a blueprint file, as JSON,
+// with the positions of its fields in the file
+type document struct {
+	name      string
+	content   []byte
+	positions map[string]Position
+}
+
+// readDocuments returns the bricks of the given blueprint file:
+// a stream of one or more JSON values, or YAML documents for the ".yaml" and ".yml" files.
+// When there are several documents, their names are indexed, such as "bricks.yaml[1]".
+func readDocuments(file BlueprintFile) ([]document, error) {
+	var docs []document
+	var err error
+	switch strings.ToLower(filepath.Ext(file.Name)) {
+	case ".yaml", ".yml":
+		docs, err = readYAMLDocuments(file.Content)
+	default:
+		docs, err = readJSONDocuments(file.Content)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, errors.New("no brick")
+	}
+
+	for i := range docs {
+		docs[i].name = file.Name
+		if len(docs) > 1 {
+			docs[i].name = fmt.Sprintf("%s[%d]", file.Name, i)
+		}
+	}
+	return docs, nil
+}
+
+// locate sets the document name and the position of the field on all the given errors
+func (d document) locate(errs []error) []error {
+	located := make([]error, 0, len(errs))
+	for _, err := range errs {
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) {
+			located = append(located, &FieldError{File: d.name, Message: err.Error()})
+			continue
+		}
+		fieldErr.File = d.name
+		fieldErr.Position = d.position(fieldErr.Path)
+		located = append(located, fieldErr)
+	}
+	return located
+}
+
+// position returns the position of the given field - or of its closest parent
+func (d document) position(path string) Position {
+	path = strings.ToLower(path)
+	for {
+		if position, ok := d.positions[path]; ok {
+			return position
+		}
+		if path == "" {
+			return Position{}
+		}
+		path = parentPath(path)
+	}
+}
+
+func parentPath(path string) string {
+	if strings.HasSuffix(path, "]") {
+		if i := strings.LastIndex(path, "["); i >= 0 {
+			return path[:i]
+		}
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+func readYAMLDocuments(content []byte) ([]document, error) {
+	var docs []document
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		if len(node.Content) == 0 {
+			// empty document
+			continue
+		}
+
+		positions := make(map[string]Position)
+		value, err := yamlValue(node.Content[0], "", positions)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, document{content: data, positions: positions})
+	}
+	return docs, nil
+}
+
+// yamlValue converts the given YAML node to a JSON value,
+// and records the positions of its fields
+func yamlValue(node *yaml.Node, path string, positions map[string]Position) (any, error) {
+	positions[strings.ToLower(path)] = Position{Line: node.Line, Column: node.Column}
+	switch node.Kind {
+	case yaml.AliasNode:
+		return yamlValue(node.Alias, path, positions)
+	case yaml.MappingNode:
+		object := make(map[string]any, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			if keyNode.ShortTag() == "!!merge" {
+				return nil, fmt.Errorf("line %d: merge keys are not supported", keyNode.Line)
+			}
+			value, err := yamlValue(valueNode, joinPath(path, keyNode.Value), positions)
+			if err != nil {
+				return nil, err
+			}
+			object[keyNode.Value] = value
+			// the position of a field is the position of its key
+			positions[strings.ToLower(joinPath(path, keyNode.Value))] = Position{Line: keyNode.Line, Column: keyNode.Column}
+		}
+		return object, nil
+	case yaml.SequenceNode:
+		array := make([]any, 0, len(node.Content))
+		for i, itemNode := range node.Content {
+			value, err := yamlValue(itemNode, fmt.Sprintf("%s[%d]", path, i), positions)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		return array, nil
+	case yaml.ScalarNode:
+		var value any
+		switch node.ShortTag() {
+		case "!!null":
+			return nil, nil
+		case "!!bool", "!!int", "!!float":
+			if err := node.Decode(&value); err != nil {
+				return nil, fmt.Errorf("line %d: %w", node.Line, err)
+			}
+			return value, nil
+		default:
+			// strings, and the other scalars such as timestamps, are kept as written
+			return node.Value, nil
+		}
+	default:
+		return nil, fmt.Errorf("line %d: unsupported YAML node", node.Line)
+	}
+}
+
+func readJSONDocuments(content []byte) ([]document, error) {
+	var docs []document
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	for {
+		start := skipJSONSpace(content, int(decoder.InputOffset()))
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				// the offset is after the invalid character
+				position := offsetPosition(content, max(int(syntaxErr.Offset)-1, 0))
+				return nil, fmt.Errorf("invalid JSON at line %d, column %d: %w", position.Line, position.Column, err)
+			}
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+
+		positions := make(map[string]Position)
+		jsonPositions(content, start, positions)
+		docs = append(docs, document{content: raw, positions: positions})
+	}
+	return docs, nil
+}
+
+// jsonPositions records the positions of the fields of the JSON value starting at the given offset
+func jsonPositions(content []byte, start int, positions map[string]Position) {
+	decoder := json.NewDecoder(bytes.NewReader(content[start:]))
+	// the path of each open object or array, and the index of the next array item
+	type frame struct {
+		path  string
+		array bool
+		index int
+		key   bool
+	}
+	var stack []frame
+	path := ""
+	offset := func() int { return start + skipJSONSpace(content[start:], int(decoder.InputOffset())) }
+	for {
+		tokenOffset := offset()
+		token, err := decoder.Token()
+		if err != nil {
+			return
+		}
+
+		if len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			switch {
+			case top.array:
+				if delim, ok := token.(json.Delim); !ok || delim != ']' {
+					path = fmt.Sprintf("%s[%d]", top.path, top.index)
+					top.index++
+					positions[strings.ToLower(path)] = offsetPosition(content, tokenOffset)
+				}
+			case !top.key:
+				if key, ok := token.(string); ok {
+					path = joinPath(top.path, key)
+					positions[strings.ToLower(path)] = offsetPosition(content, tokenOffset)
+					top.key = true
+					continue
+				}
+			default:
+				top.key = false
+			}
+		} else {
+			path = ""
+			positions[""] = offsetPosition(content, tokenOffset)
+		}
+
+		switch token {
+		case json.Delim('{'):
+			stack = append(stack, frame{path: path})
+		case json.Delim('['):
+			stack = append(stack, frame{path: path, array: true})
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			return
+		}
+	}
+}
+
+// skipJSONSpace returns the offset of the next token, after the whitespaces and the separators
+func skipJSONSpace(content []byte, offset int) int {
+	for offset < len(content) && strings.IndexByte(" \t\r\n,:", content[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// offsetPosition returns the 1-based line and column of the given byte offset
+func offsetPosition(content []byte, offset int) Position {
+	offset = min(offset, len(content))
+	line := 1 + bytes.Count(content[:offset], []byte("\n"))
+	column := 1 + utf8.RuneCount(content[bytes.LastIndexByte(content[:offset], '\n')+1:offset])
+	return Position{Line: line, Column: column}
+}
+
+// String returns the position as "line:column"
+func (p Position) String() string {
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+}
@@ -0,0 +1,85 @@
+package brickspec
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadDocuments(t *testing.T) {
+	jsonDocs, err := readDocuments(BlueprintFile{Name: "bricks.json", Content: []byte(`{
+  "kind": "test",
+  "name": "app",
+  "spec": {"phases": ["package"], "produces": ["app_binary"], "when": {"env": ["CI"]}}
+}
+{"kind": "test", "name": "tests", "spec": {"phases": ["test"], "consumes": ["app_binary"]}}`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	yamlDocs, err := readDocuments(BlueprintFile{Name: "bricks.yaml", Content: []byte(`kind: test
+name: app
+spec:
+  phases: [package]
+  produces:
+    - app_binary
+  when:
+    env: [CI]
+---
+kind: test
+name: tests
+spec: {phases: [test], consumes: [app_binary]}
+`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	registry := newTestRegistry()
+	if len(jsonDocs) != 2 || len(yamlDocs) != 2 {
+		t.Fatalf("expected 2 documents, got %d and %d", len(jsonDocs), len(yamlDocs))
+	}
+	for i := range jsonDocs {
+		jsonBrick, jsonErrs := registry.renderDocument(jsonDocs[i])
+		yamlBrick, yamlErrs := registry.renderDocument(yamlDocs[i])
+		if len(jsonErrs) > 0 || len(yamlErrs) > 0 {
+			t.Fatalf("unexpected errors: %v %v", jsonErrs, yamlErrs)
+		}
+		if !reflect.DeepEqual(jsonBrick.spec, yamlBrick.spec) || !reflect.DeepEqual(jsonBrick.when, yamlBrick.when) {
+			t.Errorf("document %d: JSON and YAML decode differently: %+v and %+v", i, jsonBrick, yamlBrick)
+		}
+	}
+
+	for _, tc := range []struct {
+		doc      document
+		path     string
+		expected Position
+	}{
+		{doc: jsonDocs[0], path: "spec.produces[0]", expected: Position{Line: 4, Column: 48}},
+		{doc: jsonDocs[0], path: "spec.when.env", expected: Position{Line: 4, Column: 72}},
+		{doc: jsonDocs[1], path: "spec.consumes", expected: Position{Line: 6, Column: 64}},
+		{doc: yamlDocs[0], path: "spec.produces[0]", expected: Position{Line: 6, Column: 7}},
+		{doc: yamlDocs[0], path: "spec.when.env", expected: Position{Line: 8, Column: 5}},
+		{doc: yamlDocs[1], path: "spec.consumes", expected: Position{Line: 12, Column: 24}},
+		{doc: yamlDocs[1], path: "spec.output.hostFilePath", expected: Position{Line: 12, Column: 1}},
+	} {
+		if actual := tc.doc.position(tc.path); actual != tc.expected {
+			t.Errorf("%s: unexpected position of %s: %s, expected %s", tc.doc.name, tc.path, actual, tc.expected)
+		}
+	}
+}
+
+func TestReadDocumentsErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"empty.yaml":   "# no brick\n",
+		"invalid.json": "{\n  \"kind\": \"test\",\n  \"spec\": {]\n}",
+		"invalid.yaml": "kind: test\nspec:\n\tphases: []\n",
+	} {
+		_, err := readDocuments(BlueprintFile{Name: name, Content: []byte(content)})
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		if name == "invalid.json" && !strings.Contains(err.Error(), "line 3, column 12") {
+			t.Errorf("%s: expected the position of the error, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package brickspec
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FieldError is an error about a specific field of a blueprint file
+type FieldError struct {
+	// File is the name of the blueprint file
+	File string
+	// Path is the path of the field in the brick, such as "spec.outputs[0].type"
+	Path string
+	// Message describes the error
+	Message string
+	// Position is the position of the field in the blueprint file, if known
+	Position Position
+}
+
+func (e *FieldError) Error() string {
+	if e.File != "" && e.Position.Line > 0 {
+		location := e.File + ":" + e.Position.String()
+		if e.Path != "" {
+			return fmt.Sprintf("%s: %s: %s", location, e.Path, e.Message)
+		}
+		return fmt.Sprintf("%s: %s", location, e.Message)
+	}
+	switch {
+	case e.File != "" && e.Path != "":
+		return fmt.Sprintf("%s: %s: %s", e.File, e.Path, e.Message)
+	case e.File != "":
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	case e.Path != "":
+		return fmt.Sprintf("%s: %s", e.Path, e.Message)
+	default:
+		return e.Message
+	}
+}
+
+// Errorf returns a new FieldError for the given path - relative to the spec
+// It can be used by the specs implementing the Validator interface.
+func Errorf(path, format string, args ...any) *FieldError {
+	return &FieldError{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// withFile sets the blueprint file name and prefixes the path of all the field errors,
+// and wraps the other errors with the file name.
+func withFile(err error, file, pathPrefix string) []error {
+	var errs []error
+	for _, err := range flattenErrors(err) {
+		var fieldErr *FieldError
+		if errors.As(err, &fieldErr) {
+			errs = append(errs, &FieldError{
+				File:    file,
+				Path:    joinPath(pathPrefix, fieldErr.Path),
+				Message: fieldErr.Message,
+			})
+			continue
+		}
+		errs = append(errs, &FieldError{
+			File:    file,
+			Path:    pathPrefix,
+			Message: err.Error(),
+		})
+	}
+	return errs
+}
+
+// flattenErrors returns the list of errors wrapped by errors.Join
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, err := range joined.Unwrap() {
+		errs = append(errs, flattenErrors(err)...)
+	}
+	return errs
+}
+
+func joinPath(prefix, path string) string {
+	switch {
+	case prefix == "":
+		return path
+	case path == "":
+		return prefix
+	case path[0] == '[':
+		return prefix + path
+	default:
+		return prefix + "." + path
+	}
+}
@@ -0,0 +1,102 @@
+package brickspec
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Artifacts describes what a brick reads and writes
+type Artifacts struct {
+	// Inputs are the paths read from the host
+	Inputs []string
+	// Outputs are the paths written on the host
+	Outputs []string
+	// Produces are the dagger variables holding the artifacts made available to the other bricks
+	Produces []string
+	// Consumes are the dagger variables produced by the other bricks
+	Consumes []string
+}
+
+// ArtifactsDeclarer can be implemented by the specs to declare their artifacts,
+// so that they can be explained and wired to the other bricks.
+type ArtifactsDeclarer interface {
+	Artifacts() Artifacts
+}
+
+// Explain returns a human-readable description of what Render would do with the given blueprint files:
+// for each brick - sorted by file name - its kind, the plan files and phases it generates,
+// its conditions, and its declared artifacts.
+func (r *Registry[B]) Explain(files []BlueprintFile) (string, error) {
+	bricks, err := r.render(files)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for i, brick := range bricks {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "%s (%s)\n", brick.file, brick.kind)
+
+		planFiles := slices.Sorted(maps.Keys(brick.plan))
+		explainLine(&sb, "phases", planPhases(planFiles))
+		for i := range planFiles {
+			planFiles[i] += ".dagger"
+		}
+		explainLine(&sb, "files", planFiles)
+		if brick.when.enabled() {
+			explainLine(&sb, "when", []string{brick.when.String()})
+		}
+
+		artifacts := brick.artifacts()
+		explainLine(&sb, "inputs", artifacts.Inputs)
+		explainLine(&sb, "outputs", artifacts.Outputs)
+		explainLine(&sb, "produces", artifacts.Produces)
+		explainLine(&sb, "consumes", artifacts.Consumes)
+	}
+	return sb.String(), nil
+}
+
+// artifacts returns the sorted artifacts declared by the spec of the brick
+func (b renderedBrick) artifacts() Artifacts {
+	var artifacts Artifacts
+	if declarer, ok := b.spec.(ArtifactsDeclarer); ok {
+		artifacts = declarer.Artifacts()
+	}
+	// the interpolated outputs of the other bricks are consumed too
+	artifacts.Consumes = append(artifacts.Consumes, interpolatedVariables(reflect.ValueOf(b.spec))...)
+	for _, values := range []*[]string{&artifacts.Inputs, &artifacts.Outputs, &artifacts.Produces, &artifacts.Consumes} {
+		*values = sortedValues(*values)
+	}
+	return artifacts
+}
+
+// planPhases returns the sorted phases of the given plan files, named "<phase>_<brick>"
+func planPhases(planFiles []string) []string {
+	var phases []string
+	for _, name := range planFiles {
+		phase, _, _ := strings.Cut(name, "_")
+		phases = append(phases, phase)
+	}
+	return sortedValues(phases)
+}
+
+// sortedValues returns the sorted and deduplicated non-empty values
+func sortedValues(values []string) []string {
+	values = slices.DeleteFunc(slices.Clone(values), func(value string) bool {
+		return value == ""
+	})
+	slices.Sort(values)
+	return slices.Compact(values)
+}
+
+func explainLine(sb *strings.Builder, label string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(sb, "  %-9s %s\n", label+":", strings.Join(values, ", "))
+}
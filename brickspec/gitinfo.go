@@ -0,0 +1,71 @@
+package brickspec
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// GitInfoModuleRef is the reference of the mason-git-info module, without version:
+// it resolves the git values of the interpolated spec strings, and checks the git conditions of the bricks.
+const GitInfoModuleRef = "github.com/vbehar/mason-modules/mason-git-info"
+
+// gitInfoVar is the dagger variable holding the mason-git-info module, in the scripts using it
+const gitInfoVar = "mason_git_info"
+
+var gitInfoVarRegexp = regexp.MustCompile(`\$` + gitInfoVar + `\b`)
+
+// CIEnvVars are the environment variables of the CI systems giving the branch name of a detached HEAD,
+// passed by default to the mason-git-info module: see its gitrepo.CIBranchEnvVars.
+var CIEnvVars = []string{
+	"GITHUB_HEAD_REF",
+	"GITHUB_REF_TYPE",
+	"GITHUB_REF_NAME",
+	"CI_MERGE_REQUEST_SOURCE_BRANCH_NAME",
+	"CI_COMMIT_BRANCH",
+	"BITBUCKET_BRANCH",
+	"BUILDKITE_BRANCH",
+	"CIRCLE_BRANCH",
+	"DRONE_SOURCE_BRANCH",
+	"TRAVIS_PULL_REQUEST_BRANCH",
+	"TRAVIS_BRANCH",
+	"CHANGE_BRANCH",
+	"BRANCH_NAME",
+	"GIT_BRANCH",
+}
+
+// GitInfoCommand returns the command loading the mason-git-info module from the given reference,
+// for the git repository of the given host directory.
+// A nil ciEnv defaults to the CIEnvVars of the host, as KEY=${env.KEY:-}.
+func GitInfoCommand(moduleRef, gitDirectory string, fetchMissingRefs bool, ciEnv []string) *Cmd {
+	if ciEnv == nil {
+		for _, name := range CIEnvVars {
+			ciEnv = append(ciEnv, name+"=${env."+name+":-}")
+		}
+	}
+	return Command(moduleRef).
+		RefFlag("git-directory", Sub(HostDirectory(gitDirectory, nil, nil))).
+		BoolFlag("fetch-missing-refs", fetchMissingRefs).
+		ListFlag("ci-env", ciEnv)
+}
+
+// gitInfoRef returns the reference of the mason-git-info module pinned to the version of the given module reference -
+// the mason modules are released together - so that a pinned blueprint always runs the same git commands.
+// The modules of other repositories get the unversioned reference.
+func gitInfoRef(moduleRef string) string {
+	ref, version, found := strings.Cut(moduleRef, "@")
+	if found && path.Dir(ref) == path.Dir(GitInfoModuleRef) {
+		return GitInfoModuleRef + "@" + version
+	}
+	return GitInfoModuleRef
+}
+
+// withGitInfo returns the given script of a brick, starting with the definition of the mason-git-info variable
+// if the script uses it - with the git options of the conditions of the brick
+func withGitInfo(script, moduleRef string, when When) string {
+	if !gitInfoVarRegexp.MatchString(script) {
+		return script
+	}
+	cmd := GitInfoCommand(gitInfoRef(moduleRef), ".", when.FetchMissingRefs, when.CIEnv)
+	return new(Script).Assign(gitInfoVar, cmd).String() + script
+}
@@ -0,0 +1,48 @@
+package brickspec
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGitInfoRef(t *testing.T) {
+	for moduleRef, expected := range map[string]string{
+		"github.com/vbehar/mason-modules/golang":                "github.com/vbehar/mason-modules/mason-git-info",
+		"github.com/vbehar/mason-modules/golang@v1.2.3":         "github.com/vbehar/mason-modules/mason-git-info@v1.2.3",
+		"github.com/vbehar/mason-modules/mason-git-info@0a1b2c": "github.com/vbehar/mason-modules/mason-git-info@0a1b2c",
+		"github.com/example/modules/golang@v1.2.3":              "github.com/vbehar/mason-modules/mason-git-info",
+	} {
+		if actual := gitInfoRef(moduleRef); actual != expected {
+			t.Errorf("unexpected ref for %s: %s, expected %s", moduleRef, actual, expected)
+		}
+	}
+}
+
+func TestGitInfoCommand(t *testing.T) {
+	cmd := GitInfoCommand(GitInfoModuleRef, "repo", false, nil).String()
+	for _, name := range CIEnvVars {
+		if !strings.Contains(cmd, name+`="${`+name+`:-}"`) {
+			t.Errorf("expected the %s environment variable of the host in the default ci-env: %s", name, cmd)
+		}
+	}
+
+	cmd = GitInfoCommand(GitInfoModuleRef, "repo", true, []string{}).String()
+	if expected := "github.com/vbehar/mason-modules/mason-git-info --git-directory $(host | directory repo) --fetch-missing-refs"; cmd != expected {
+		t.Errorf("unexpected command: %s, expected %s", cmd, expected)
+	}
+}
+
+func TestWithGitInfo(t *testing.T) {
+	script := "version=$($mason_git_info | tag)\n"
+	when := When{FetchMissingRefs: true, CIEnv: []string{"BRANCH=${env.BRANCH:-main}"}}
+	expected := `mason_git_info=$(github.com/vbehar/mason-modules/mason-git-info@v1.2.3 --git-directory $(host | directory .) --fetch-missing-refs --ci-env BRANCH="${BRANCH:-main}")` + "\n" + script
+	if actual := withGitInfo(script, "github.com/vbehar/mason-modules/golang@v1.2.3", when); actual != expected {
+		t.Errorf("unexpected script:\n%s\nexpected:\n%s", actual, expected)
+	}
+
+	for _, script := range []string{"", "$app_binary | export bin/app\n", "$mason_git_info_report | export report.json\n"} {
+		if actual := withGitInfo(script, "github.com/vbehar/mason-modules/golang", When{}); actual != script {
+			t.Errorf("unexpected script:\n%s\nexpected it unchanged", actual)
+		}
+	}
+}
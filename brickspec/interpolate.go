@@ -0,0 +1,211 @@
+package brickspec
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// namespaces are the namespaces of the interpolated expressions
+var namespaces = []string{"bricks", "env", "git"}
+
+// gitValues are the functions of the mason-git-info module returning each git value
+var gitValues = map[string]string{
+	"branch": "branch-name",
+	"tag":    "tag",
+	"sha":    "short-sha",
+}
+
+// segment is a part of an interpolated string: either a literal, or an expression
+type segment struct {
+	literal   string
+	namespace string
+	key       string
+	defValue  string
+	hasDef    bool
+}
+
+// parseInterpolation splits the given string into literals and ${namespace.key} expressions.
+// The supported expressions are:
+//   - ${env.NAME} and ${env.NAME:-default} for the environment variables of the host
+//   - ${git.branch}, ${git.tag} and ${git.sha} for the current branch, tag and short commit SHA
+//   - ${bricks.name} for the content of a dagger variable produced by another brick
+//
+// Any other "${...}" - such as the ${HOME} of a shell command - is kept as a literal,
+// and a literal "${namespace.key}" is written "$${namespace.key}".
+func parseInterpolation(value string) ([]segment, error) {
+	var segments []segment
+	var literal strings.Builder
+	for value != "" {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			literal.WriteString(value)
+			break
+		}
+		if start > 0 && value[start-1] == '$' {
+			literal.WriteString(value[:start-1] + "${")
+			value = value[start+2:]
+			continue
+		}
+		literal.WriteString(value[:start])
+		end := strings.Index(value[start:], "}")
+		if end < 0 {
+			if isExpression(value[start+2:]) {
+				return nil, fmt.Errorf("unterminated expression %q", value[start:])
+			}
+			literal.WriteString(value[start:])
+			break
+		}
+		expr := value[start+2 : start+end]
+		if !isExpression(expr) {
+			literal.WriteString(value[start : start+end+1])
+			value = value[start+end+1:]
+			continue
+		}
+		value = value[start+end+1:]
+
+		seg, err := parseExpression(expr)
+		if err != nil {
+			return nil, err
+		}
+		if literal.Len() > 0 {
+			segments = append(segments, segment{literal: literal.String()})
+			literal.Reset()
+		}
+		segments = append(segments, seg)
+	}
+	if literal.Len() > 0 {
+		segments = append(segments, segment{literal: literal.String()})
+	}
+	return segments, nil
+}
+
+// isExpression reports whether the given content of "${...}" is in one of the namespaces
+func isExpression(expr string) bool {
+	namespace, _, found := strings.Cut(expr, ".")
+	return found && slices.Contains(namespaces, namespace)
+}
+
+func parseExpression(expr string) (segment, error) {
+	ref, defValue, hasDef := strings.Cut(expr, ":-")
+	namespace, key, _ := strings.Cut(ref, ".")
+	seg := segment{namespace: namespace, key: key, defValue: defValue, hasDef: hasDef}
+	switch namespace {
+	case "env":
+		if !identifierRegexp.MatchString(key) {
+			return seg, fmt.Errorf("invalid expression ${%s}: %q is not a valid environment variable name", expr, key)
+		}
+		return seg, nil
+	case "git":
+		if _, ok := gitValues[key]; !ok {
+			return seg, fmt.Errorf("invalid expression ${%s}: undefined git value %q, must be one of %s",
+				expr, key, strings.Join(slices.Sorted(maps.Keys(gitValues)), ", "))
+		}
+	case "bricks":
+		if !identifierRegexp.MatchString(key) {
+			return seg, fmt.Errorf("invalid expression ${%s}: %q is not a valid variable name", expr, key)
+		}
+	}
+	if hasDef {
+		return seg, fmt.Errorf("invalid expression ${%s}: only the env values can have a default", expr)
+	}
+	return seg, nil
+}
+
+// Word returns the given value as a single word of the dagger shell, just like Quote,
+// but with its ${namespace.key} expressions resolved at runtime by the shell.
+// The value must have been validated - by DecodeSpec - first: invalid expressions are kept as literals.
+func Word(value string) string {
+	if !strings.Contains(value, "${") {
+		return Quote(value)
+	}
+	segments, err := parseInterpolation(value)
+	if err != nil {
+		return Quote(value)
+	}
+	if len(segments) == 0 {
+		return Quote("")
+	}
+
+	var sb strings.Builder
+	for _, seg := range segments {
+		if seg.namespace == "" {
+			sb.WriteString(Quote(seg.literal))
+			continue
+		}
+		sb.WriteString(`"` + seg.shellExpression() + `"`)
+	}
+	return sb.String()
+}
+
+// shellExpression returns the expression of the dagger shell resolving the segment, to be double-quoted
+func (s segment) shellExpression() string {
+	switch s.namespace {
+	case "env":
+		if s.hasDef {
+			return "${" + s.key + ":-" + escapeDoubleQuoted(s.defValue) + "}"
+		}
+		return "${" + s.key + "?environment variable is not defined}"
+	case "git":
+		return Sub(FromRef(Var(gitInfoVar)).Pipe(gitValues[s.key]))
+	default:
+		return Sub(FromRef(Var(s.key)).Pipe("contents"))
+	}
+}
+
+// EscapeExpressions returns the given value with its "${" escaped, so that Word keeps it as a literal
+func EscapeExpressions(value string) string {
+	return strings.ReplaceAll(value, "${", "$${")
+}
+
+func escapeDoubleQuoted(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`").Replace(value)
+}
+
+// interpolationErrors returns the errors of the expressions of the given string
+func interpolationErrors(value, path string) []error {
+	if !strings.Contains(value, "${") {
+		return nil
+	}
+	if _, err := parseInterpolation(value); err != nil {
+		return []error{Errorf(path, "%v", err)}
+	}
+	return nil
+}
+
+// interpolatedVariables returns the dagger variables referenced by the ${bricks.name} expressions
+// of all the strings of the given value
+func interpolatedVariables(value reflect.Value) []string {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !value.IsNil() {
+			return interpolatedVariables(value.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		var names []string
+		for i := range value.Len() {
+			names = append(names, interpolatedVariables(value.Index(i))...)
+		}
+		return names
+	case reflect.Struct:
+		var names []string
+		for _, field := range reflect.VisibleFields(value.Type()) {
+			if field.IsExported() && !field.Anonymous {
+				names = append(names, interpolatedVariables(value.FieldByIndex(field.Index))...)
+			}
+		}
+		return names
+	case reflect.String:
+		segments, _ := parseInterpolation(value.String())
+		var names []string
+		for _, seg := range segments {
+			if seg.namespace == "bricks" {
+				names = append(names, seg.key)
+			}
+		}
+		return names
+	}
+	return nil
+}
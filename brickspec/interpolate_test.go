@@ -0,0 +1,88 @@
+package brickspec
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestWord(t *testing.T) {
+	requireShell(t)
+	t.Setenv("BRICKSPEC_VERSION", "1.2.3")
+
+	for _, tc := range []struct {
+		value    string
+		expected string
+	}{
+		{value: "plain", expected: "plain"},
+		{value: "-X main.version=${env.BRICKSPEC_VERSION}", expected: "-X main.version=1.2.3"},
+		{value: "${env.BRICKSPEC_VERSION:-dev}", expected: "1.2.3"},
+		{value: "alpine:${env.BRICKSPEC_UNDEFINED:-3.22 \"$HOME\" `id`}", expected: "alpine:3.22 \"$HOME\" `id`"},
+		{value: "it's ${env.BRICKSPEC_UNDEFINED:-}", expected: "it's "},
+		{value: "$${env.BRICKSPEC_VERSION} costs $5", expected: "${env.BRICKSPEC_VERSION} costs $5"},
+		{value: "echo ${HOME:-/root} ${vars.version} ${env.BRICKSPEC_VERSION}", expected: "echo ${HOME:-/root} ${vars.version} 1.2.3"},
+		{value: "echo ${HOME", expected: "echo ${HOME"},
+	} {
+		words := shellWords(t, Word(tc.value))
+		if len(words) != 1 || words[0] != tc.expected {
+			t.Errorf("unexpected words for %q: %q, expected %q", tc.value, words, tc.expected)
+		}
+	}
+
+	property := func(value hostileString) bool {
+		words := shellWords(t, Word(EscapeExpressions(string(value))))
+		return len(words) == 1 && words[0] == string(value)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 200}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWordUndefinedEnv(t *testing.T) {
+	requireShell(t)
+	output, err := exec.Command("sh", "-c", "printf '%s' "+Word("${env.BRICKSPEC_UNDEFINED}")).CombinedOutput()
+	if err == nil || !strings.Contains(string(output), "BRICKSPEC_UNDEFINED") {
+		t.Errorf("expected an error about the undefined variable, got %q: %v", output, err)
+	}
+}
+
+func TestWordReferences(t *testing.T) {
+	for value, expected := range map[string]string{
+		"${git.branch}":          `"$($mason_git_info | branch-name)"`,
+		"v${git.tag}-${git.sha}": `v"$($mason_git_info | tag)"-"$($mason_git_info | short-sha)"`,
+		"${bricks.app_version}":  `"$($app_version | contents)"`,
+		"echo ${HOME}":           `'echo ${HOME}'`,
+	} {
+		if actual := Word(value); actual != expected {
+			t.Errorf("unexpected word for %q: %s, expected %s", value, actual, expected)
+		}
+	}
+}
+
+func TestValidateInterpolation(t *testing.T) {
+	type spec struct {
+		Image string   `json:"image"`
+		Args  []string `json:"args"`
+	}
+	err := Validate(&spec{
+		Image: "alpine:${env.ALPINE_VERSION:-3.22}",
+		Args: []string{
+			"${git.commit}",
+			"${bricks.app-version}",
+			"${HOME}/${vars.version}",
+			"${git.tag:-dev}",
+			"${env.VERSION",
+			"$${env.VERSION",
+		},
+	})
+	expected := []string{
+		`args[0]: invalid expression ${git.commit}: undefined git value "commit", must be one of branch, sha, tag`,
+		`args[1]: invalid expression ${bricks.app-version}: "app-version" is not a valid variable name`,
+		`args[3]: invalid expression ${git.tag:-dev}: only the env values can have a default`,
+		`args[4]: unterminated expression "${env.VERSION"`,
+	}
+	if err == nil || err.Error() != strings.Join(expected, "\n") {
+		t.Errorf("unexpected error:\n%v\nexpected:\n%s", err, strings.Join(expected, "\n"))
+	}
+}
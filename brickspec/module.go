@@ -0,0 +1,157 @@
+package brickspec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// The functions of this file implement the functions shared by all the mason modules -
+// RenderPlan, ExplainPlan, Wiring, ValidateBlueprint and Schemas - on top of the dagger types,
+// which are only known by the modules, through callbacks.
+
+// BlueprintSource lists and reads the files of a blueprint directory, such as a *dagger.Directory
+type BlueprintSource struct {
+	// Entries returns the names of the files of the directory
+	Entries func(ctx context.Context) ([]string, error)
+	// Contents returns the content of the file with the given name
+	Contents func(ctx context.Context, name string) (string, error)
+}
+
+// FileContents returns the content of a file, such as the Contents method of a *dagger.File
+type FileContents func(ctx context.Context) (string, error)
+
+// Contents returns the FileContents of each of the given files, such as []*dagger.File
+func Contents[F interface {
+	Contents(ctx context.Context) (string, error)
+}](files []F) []FileContents {
+	contents := make([]FileContents, 0, len(files))
+	for _, file := range files {
+		contents = append(contents, file.Contents)
+	}
+	return contents
+}
+
+// ReadBlueprint reads all the files of the given blueprint directory
+func ReadBlueprint(ctx context.Context, blueprint BlueprintSource) ([]BlueprintFile, error) {
+	fileNames, err := blueprint.Entries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blueprint directory entries: %w", err)
+	}
+
+	files := make([]BlueprintFile, 0, len(fileNames))
+	for _, fileName := range fileNames {
+		data, err := blueprint.Contents(ctx, fileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get file contents for %s: %w", fileName, err)
+		}
+		files = append(files, BlueprintFile{
+			Name:    fileName,
+			Content: []byte(data),
+		})
+	}
+	return files, nil
+}
+
+// RenderPlan renders the plan of the given blueprint directory:
+// the generated scripts, indexed by file name - with the ".dagger" extension.
+func (r *Registry[B]) RenderPlan(ctx context.Context, blueprint BlueprintSource) (map[string]string, error) {
+	files, err := ReadBlueprint(ctx, blueprint)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := r.Render(files)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blueprint:\n%w", err)
+	}
+
+	planFiles := make(map[string]string, len(plan))
+	for name, script := range plan {
+		planFiles[name+".dagger"] = script
+	}
+	return planFiles, nil
+}
+
+// ExplainPlan describes what RenderPlan would generate for the given blueprint directory, without running anything:
+// the phases and files of each brick, its inputs and outputs on the host,
+// and the dagger variables it produces for - and consumes from - the other bricks.
+func (r *Registry[B]) ExplainPlan(ctx context.Context, blueprint BlueprintSource) (string, error) {
+	files, err := ReadBlueprint(ctx, blueprint)
+	if err != nil {
+		return "", err
+	}
+
+	explanation, err := r.Explain(files)
+	if err != nil {
+		return "", fmt.Errorf("invalid blueprint:\n%w", err)
+	}
+	return explanation, nil
+}
+
+// WiringJSON returns how the bricks of the given blueprint directory are wired, as JSON:
+// the phases they run in, and the dagger variables they produce and consume.
+// It can be given to ValidateBlueprint, by the other modules.
+func (r *Registry[B]) WiringJSON(ctx context.Context, blueprint BlueprintSource) (string, error) {
+	files, err := ReadBlueprint(ctx, blueprint)
+	if err != nil {
+		return "", err
+	}
+
+	wirings, err := r.Wiring(files)
+	if err != nil {
+		return "", fmt.Errorf("invalid blueprint:\n%w", err)
+	}
+
+	data, err := json.MarshalIndent(wirings, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal wiring: %w", err)
+	}
+	return string(data), nil
+}
+
+// ValidateBlueprint validates the given blueprint directory before running anything:
+// the bricks themselves, and how they are wired together - including the bricks of the other modules,
+// from the JSON returned by their WiringJSON function. See ValidateWiring.
+func (r *Registry[B]) ValidateBlueprint(ctx context.Context, blueprint BlueprintSource, wirings []FileContents, phases []string) error {
+	files, err := ReadBlueprint(ctx, blueprint)
+	if err != nil {
+		return err
+	}
+
+	allWirings, err := r.Wiring(files)
+	if err != nil {
+		return fmt.Errorf("invalid blueprint:\n%w", err)
+	}
+	for _, wiringFile := range wirings {
+		data, err := wiringFile(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read wiring file: %w", err)
+		}
+		var moduleWirings []BrickWiring
+		if err := json.Unmarshal([]byte(data), &moduleWirings); err != nil {
+			return fmt.Errorf("invalid wiring file: %w", err)
+		}
+		allWirings = append(allWirings, moduleWirings...)
+	}
+
+	if err := ValidateWiring(allWirings, phases); err != nil {
+		return fmt.Errorf("invalid blueprint wiring:\n%w", err)
+	}
+	return nil
+}
+
+// SchemaFiles returns the JSON Schema of each registered kind,
+// indexed by file name: the kind name with the ".schema.json" extension.
+func (r *Registry[B]) SchemaFiles() (map[string]string, error) {
+	schemas, err := r.Schemas()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string, len(schemas))
+	for kind, schema := range schemas {
+		files[kind+".schema.json"] = string(schema)
+	}
+	return files, nil
+}
@@ -0,0 +1,74 @@
+package brickspec
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// memoryBlueprint returns a blueprint source of the given files, indexed by name
+func memoryBlueprint(files map[string]string) BlueprintSource {
+	return BlueprintSource{
+		Entries: func(context.Context) ([]string, error) {
+			return slices.Sorted(maps.Keys(files)), nil
+		},
+		Contents: func(_ context.Context, name string) (string, error) {
+			content, ok := files[name]
+			if !ok {
+				return "", errors.New("file not found")
+			}
+			return content, nil
+		},
+	}
+}
+
+// memoryFile is a file of the given content
+type memoryFile string
+
+func (f memoryFile) Contents(context.Context) (string, error) {
+	return string(f), nil
+}
+
+func TestRegistryModuleFunctions(t *testing.T) {
+	ctx := context.Background()
+	registry := newTestRegistry()
+	blueprint := memoryBlueprint(map[string]string{
+		"build.json": `{"kind": "test", "name": "build", "spec": {"phases": ["package"], "produces": ["app_binary"]}}`,
+	})
+
+	plan, err := registry.RenderPlan(ctx, blueprint)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if files := slices.Sorted(maps.Keys(plan)); !slices.Equal(files, []string{"package_build.dagger"}) {
+		t.Errorf("unexpected plan files %v", files)
+	}
+
+	wiring, err := registry.WiringJSON(ctx, blueprint)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	otherModule := memoryBlueprint(map[string]string{
+		"run.json": `{"kind": "test", "name": "run", "spec": {"phases": ["run"], "consumes": ["app_binary", "missing"]}}`,
+	})
+	err = registry.ValidateBlueprint(ctx, otherModule, Contents([]memoryFile{memoryFile(wiring)}), []string{"package", "run"})
+	if err == nil || !strings.HasSuffix(err.Error(), `run.json: variable "missing" is not produced by any brick`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	schemas, err := registry.SchemaFiles()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if files := slices.Sorted(maps.Keys(schemas)); !slices.Equal(files, []string{"test.schema.json"}) {
+		t.Errorf("unexpected schema files %v", files)
+	}
+
+	_, err = registry.ExplainPlan(ctx, memoryBlueprint(map[string]string{"bad.json": `{"kind": "unknown"}`}))
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid blueprint:\n") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+// Package plantest is a golden-file test harness for the plans rendered by a brickspec registry,
+// which doesn't need a dagger engine.
+//
+// Each test case is a directory containing:
+//   - a "blueprint" directory, with the brick files to render
+//   - a "golden" directory, with the expected ".dagger" files and the "explain.txt" description of the plan
+//   - or an "error.txt" file with the expected error
+//
+// Run the tests with the -update flag to write the golden files from the current plans.
+package plantest
+
+import (
+	"flag"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/vbehar/mason-modules/brickspec"
+)
+
+const (
+	blueprintDirName = "blueprint"
+	goldenDirName    = "golden"
+	errorFileName    = "error.txt"
+	explainFileName  = "explain.txt"
+	planFileExt      = ".dagger"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the plan tests")
+
+// Renderer renders and explains the plan of the given blueprint files, such as a brickspec.Registry
+type Renderer interface {
+	Render(files []brickspec.BlueprintFile) (map[string]string, error)
+	Explain(files []brickspec.BlueprintFile) (string, error)
+}
+
+// Run runs a sub-test for each test case directory in the given directory,
+// comparing the rendered plan with the golden files.
+func Run(t *testing.T, dir string, renderer Renderer) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read test cases: %v", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		caseDir := filepath.Join(dir, entry.Name())
+		t.Run(entry.Name(), func(t *testing.T) {
+			runCase(t, caseDir, renderer)
+		})
+	}
+}
+
+func runCase(t *testing.T, caseDir string, renderer Renderer) {
+	files, err := readBlueprint(filepath.Join(caseDir, blueprintDirName))
+	if err != nil {
+		t.Fatalf("failed to read blueprint: %v", err)
+	}
+
+	actual := make(map[string]string)
+	plan, err := renderer.Render(files)
+	if err != nil {
+		actual[errorFileName] = err.Error() + "\n"
+	} else {
+		for name, script := range plan {
+			actual[name+planFileExt] = script
+		}
+		explanation, err := renderer.Explain(files)
+		if err != nil {
+			t.Fatalf("failed to explain a valid blueprint: %v", err)
+		}
+		actual[explainFileName] = explanation
+	}
+
+	goldenDir := filepath.Join(caseDir, goldenDirName)
+	if *update {
+		if err := writeGolden(goldenDir, actual); err != nil {
+			t.Fatalf("failed to update golden files: %v", err)
+		}
+		return
+	}
+
+	expected, err := readGolden(goldenDir)
+	if err != nil {
+		t.Fatalf("failed to read golden files - run with -update to create them: %v", err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(expected)) {
+		actualContent, ok := actual[name]
+		switch {
+		case !ok:
+			t.Errorf("missing %s", name)
+		case actualContent != expected[name]:
+			t.Errorf("unexpected %s:\n%s\nexpected:\n%s", name, actualContent, expected[name])
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(actual)) {
+		if _, ok := expected[name]; !ok {
+			t.Errorf("unexpected %s:\n%s", name, actual[name])
+		}
+	}
+}
+
+func readBlueprint(dir string) ([]brickspec.BlueprintFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]brickspec.BlueprintFile, 0, len(entries))
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, brickspec.BlueprintFile{
+			Name:    entry.Name(),
+			Content: content,
+		})
+	}
+	return files, nil
+}
+
+func readGolden(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	golden := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		golden[entry.Name()] = string(content)
+	}
+	return golden, nil
+}
+
+func writeGolden(dir string, files map[string]string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
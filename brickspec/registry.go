@@ -0,0 +1,177 @@
+// Package brickspec is the shared spec layer of the mason modules:
+// a registry of brick kinds, which strictly decodes and validates the bricks of a blueprint,
+// before rendering their plans.
+package brickspec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// BlueprintFile is a file of the blueprint directory, containing a brick
+type BlueprintFile struct {
+	Name    string
+	Content []byte
+}
+
+// Registry holds the brick kinds supported by a module.
+// B is the type of the brick, usually mason.Brick.
+type Registry[B any] struct {
+	brickKindAndSpec func(B) (string, string, json.RawMessage)
+	kinds            map[string]*kind[B]
+}
+
+type kind[B any] struct {
+	name     string
+	specType reflect.Type
+	decode   func(rawSpec json.RawMessage) (any, error)
+	plan     func(spec any, brick B) map[string]string
+}
+
+// NewRegistry returns a new empty registry.
+// The given function returns the kind, the module reference and the raw spec of a brick.
+func NewRegistry[B any](brickKindAndSpec func(B) (kind, moduleRef string, spec json.RawMessage)) *Registry[B] {
+	return &Registry[B]{
+		brickKindAndSpec: brickKindAndSpec,
+		kinds:            make(map[string]*kind[B]),
+	}
+}
+
+// Register adds a brick kind to the registry,
+// with the function rendering the plan of a brick from its decoded spec - usually a method expression
+// such as GoBinarySpec.Plan.
+// Kinds are case-insensitive.
+func Register[S, B any](r *Registry[B], kindName string, plan func(S, B) map[string]string) {
+	r.kinds[strings.ToLower(kindName)] = &kind[B]{
+		name:     kindName,
+		specType: reflect.TypeFor[S](),
+		decode: func(rawSpec json.RawMessage) (any, error) {
+			var spec S
+			if err := DecodeSpec(rawSpec, &spec); err != nil {
+				return nil, err
+			}
+			return spec, nil
+		},
+		plan: func(spec any, brick B) map[string]string {
+			return plan(spec.(S), brick)
+		},
+	}
+}
+
+// Kinds returns the sorted names of the registered kinds
+func (r *Registry[B]) Kinds() []string {
+	var names []string
+	for _, k := range r.kinds {
+		names = append(names, k.name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Render decodes and validates the bricks of the given blueprint files,
+// and returns the plan: the generated scripts indexed by file name - without the ".dagger" extension.
+// All the invalid blueprint files are reported in the returned error.
+func (r *Registry[B]) Render(files []BlueprintFile) (map[string]string, error) {
+	bricks, err := r.render(files)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := make(map[string]string)
+	for _, brick := range bricks {
+		maps.Copy(plan, brick.plan)
+	}
+	return plan, nil
+}
+
+// renderedBrick is a valid brick of a blueprint, with its decoded spec and its plan
+type renderedBrick struct {
+	file string
+	kind string
+	spec any
+	when When
+	plan map[string]string
+}
+
+// render decodes, validates and renders the bricks of the given blueprint files, sorted by file name
+func (r *Registry[B]) render(files []BlueprintFile) ([]renderedBrick, error) {
+	var bricks []renderedBrick
+	planFiles := make(map[string]string)
+	var errs []error
+	for _, file := range slices.SortedFunc(slices.Values(files), func(a, b BlueprintFile) int {
+		return strings.Compare(a.Name, b.Name)
+	}) {
+		docs, err := readDocuments(file)
+		if err != nil {
+			errs = append(errs, &FieldError{File: file.Name, Message: fmt.Sprintf("invalid blueprint file: %v", err)})
+			continue
+		}
+		for _, doc := range docs {
+			brick, docErrs := r.renderDocument(doc)
+			if len(docErrs) > 0 {
+				errs = append(errs, doc.locate(docErrs)...)
+				continue
+			}
+			for _, name := range slices.Sorted(maps.Keys(brick.plan)) {
+				if otherFile, ok := planFiles[name]; ok {
+					errs = append(errs, &FieldError{
+						File:    doc.name,
+						Message: fmt.Sprintf("plan file %q is already generated by %s", name, otherFile),
+					})
+					delete(brick.plan, name)
+					continue
+				}
+				planFiles[name] = doc.name
+			}
+			bricks = append(bricks, brick)
+		}
+	}
+
+	return bricks, errors.Join(errs...)
+}
+
+// renderDocument decodes, validates and renders a brick.
+// The returned errors are FieldErrors relative to the brick.
+func (r *Registry[B]) renderDocument(doc document) (renderedBrick, []error) {
+	var brick B
+	if err := json.Unmarshal(doc.content, &brick); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return renderedBrick{}, []error{Errorf(typeErr.Field, "invalid brick: cannot use a JSON %s as %s", typeErr.Value, typeErr.Type)}
+		}
+		return renderedBrick{}, []error{Errorf("", "invalid brick: %v", err)}
+	}
+
+	kindName, moduleRef, rawSpec := r.brickKindAndSpec(brick)
+	k, ok := r.kinds[strings.ToLower(kindName)]
+	if !ok {
+		return renderedBrick{}, []error{
+			Errorf("kind", "unknown kind %q, must be one of %s", kindName, strings.Join(r.Kinds(), ", ")),
+		}
+	}
+
+	when, rawSpec, err := splitWhen(rawSpec)
+	if err != nil {
+		return renderedBrick{}, withFile(err, "", "spec")
+	}
+	spec, err := k.decode(rawSpec)
+	if err != nil {
+		return renderedBrick{}, withFile(err, "", "spec")
+	}
+	brickPlan := k.plan(spec, brick)
+	for name, script := range brickPlan {
+		brickPlan[name] = withGitInfo(when.wrap(script, doc.name), moduleRef, when)
+	}
+	return renderedBrick{
+		file: doc.name,
+		kind: k.name,
+		spec: spec,
+		when: when,
+		plan: brickPlan,
+	}, nil
+}
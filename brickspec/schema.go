@@ -0,0 +1,125 @@
+package brickspec
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+var rawMessageType = reflect.TypeFor[json.RawMessage]()
+
+// Schemas returns a JSON Schema for each registered kind, indexed by kind name.
+// Each schema describes a whole brick file: its spec is generated from the spec struct,
+// using the `json`, `description`, `default`, `enum` and `validate` struct tags.
+func (r *Registry[B]) Schemas() (map[string][]byte, error) {
+	schemas := make(map[string][]byte, len(r.kinds))
+	for _, k := range r.kinds {
+		// the brick itself may have fields which are not described by its struct
+		schema := jsonSchema(reflect.TypeFor[B](), false)
+		schema["$schema"] = jsonSchemaDraft
+		schema["title"] = k.name
+		properties, _ := schema["properties"].(map[string]any)
+		if properties == nil {
+			properties = make(map[string]any)
+			schema["properties"] = properties
+		}
+		properties["kind"] = map[string]any{
+			"type":        "string",
+			"description": "The kind of the brick.",
+			"enum":        slices.Compact([]string{k.name, strings.ToLower(k.name)}),
+		}
+		specSchema := jsonSchema(k.specType, true)
+		if specProperties, ok := specSchema["properties"].(map[string]any); ok {
+			whenSchema := jsonSchema(reflect.TypeFor[When](), true)
+			whenSchema["description"] = "Conditions of the brick: it is skipped if they are not met."
+			specProperties[whenField] = whenSchema
+		}
+		properties["spec"] = specSchema
+
+		data, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		schemas[k.name] = data
+	}
+	return schemas, nil
+}
+
+// jsonSchema returns the JSON Schema of the given type.
+// In strict mode, objects don't allow additional properties - just like DecodeSpec.
+func jsonSchema(t reflect.Type, strict bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == rawMessageType {
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem(), strict)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchema(t.Elem(), strict)}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		var required []string
+		for _, field := range reflect.VisibleFields(t) {
+			if !field.IsExported() || field.Anonymous {
+				continue
+			}
+			name := jsonName(field)
+			if name == "-" {
+				continue
+			}
+			properties[name] = fieldSchema(field, strict)
+			if slices.Contains(strings.Split(field.Tag.Get("validate"), ","), "required") {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]any{
+			"type":       "object",
+			"properties": properties,
+		}
+		if strict {
+			schema["additionalProperties"] = false
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	default:
+		return map[string]any{}
+	}
+}
+
+func fieldSchema(field reflect.StructField, strict bool) map[string]any {
+	schema := jsonSchema(field.Type, strict)
+	if description, ok := field.Tag.Lookup("description"); ok {
+		schema["description"] = description
+	}
+	if slices.Contains(strings.Split(field.Tag.Get("validate"), ","), "identifier") {
+		schema["pattern"] = identifierRegexp.String()
+	}
+	if enum, ok := field.Tag.Lookup("enum"); ok {
+		schema["enum"] = strings.Split(enum, ",")
+	}
+	if defaultValue, ok := field.Tag.Lookup("default"); ok {
+		value := reflect.New(field.Type)
+		if err := setDefault(value.Elem(), defaultValue); err == nil {
+			schema["default"] = value.Elem().Interface()
+		}
+	}
+	return schema
+}
@@ -0,0 +1,232 @@
+package brickspec
+
+import (
+	"bytes"
+	"encoding/csv"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	invalidIdentChar = regexp.MustCompile(`[^A-Za-z0-9_]`)
+	safeWordRegexp   = regexp.MustCompile(`^[A-Za-z0-9_./:@%+=,-]+$`)
+)
+
+// Quote returns the given value as a single word of the dagger shell,
+// single-quoted if it contains any character which is not safe.
+func Quote(value string) string {
+	if safeWordRegexp.MatchString(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// List returns the given values as a single word for a list argument of the dagger shell:
+// the values are CSV-encoded - as expected by the list flags - and quoted.
+func List(values []string) string {
+	return Quote(csvRecord(values))
+}
+
+// listWord is the interpolated version of List
+func listWord(values []string) string {
+	return Word(csvRecord(values))
+}
+
+func csvRecord(values []string) string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(values) //nolint:errcheck // writing to a buffer can't fail
+	w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Ident returns the given name as a valid dagger shell variable name,
+// replacing the invalid characters with underscores.
+func Ident(name string) string {
+	if identifierRegexp.MatchString(name) {
+		return name
+	}
+	name = invalidIdentChar.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// Var returns a reference to the given dagger shell variable
+func Var(name string) string {
+	return "$" + Ident(name)
+}
+
+// Sub returns the substitution of the given command: $(cmd)
+func Sub(cmd *Cmd) string {
+	return "$(" + cmd.String() + ")"
+}
+
+// HostDirectory returns the command loading a directory from the host - defaulting to the current directory
+func HostDirectory(path string, include, exclude []string) *Cmd {
+	if path == "" {
+		path = "."
+	}
+	return Command("host").
+		Pipe("directory", path).
+		ListFlag("include", include).
+		ListFlag("exclude", exclude)
+}
+
+// HostFile returns the command loading a file from the host
+func HostFile(path string) *Cmd {
+	return Command("host").Pipe("file", path)
+}
+
+// Cmd is a dagger shell command, made of one or more pipeline stages.
+// All the values are quoted, except the references returned by Var and Sub.
+// The arguments and flag values are interpolated: see Word.
+type Cmd struct {
+	stages [][]string
+}
+
+// Command starts a new command, with the given quoted arguments
+func Command(name string, args ...string) *Cmd {
+	return (&Cmd{}).Pipe(name, args...)
+}
+
+// FromRef starts a new command from a reference returned by Var or Sub,
+// such as "$binary | export path"
+func FromRef(ref string) *Cmd {
+	return &Cmd{stages: [][]string{{ref}}}
+}
+
+// Pipe adds a new stage to the pipeline: "| name args..."
+func (c *Cmd) Pipe(name string, args ...string) *Cmd {
+	c.stages = append(c.stages, []string{Quote(name)})
+	return c.Arg(args...)
+}
+
+func (c *Cmd) add(words ...string) *Cmd {
+	last := len(c.stages) - 1
+	c.stages[last] = append(c.stages[last], words...)
+	return c
+}
+
+// Arg adds quoted positional arguments
+func (c *Cmd) Arg(values ...string) *Cmd {
+	for _, value := range values {
+		c.add(Word(value))
+	}
+	return c
+}
+
+// ListArg adds a positional list argument
+func (c *Cmd) ListArg(values []string) *Cmd {
+	return c.add(listWord(values))
+}
+
+// Ref adds positional arguments which are references returned by Var or Sub
+func (c *Cmd) Ref(refs ...string) *Cmd {
+	return c.add(refs...)
+}
+
+// Flag adds a "--name value" flag, if the value is not empty
+func (c *Cmd) Flag(name, value string) *Cmd {
+	if value == "" {
+		return c
+	}
+	return c.add("--"+name, Word(value))
+}
+
+// FlagAllowEmpty adds a "--name value" flag, even if the value is empty -
+// for the options whose empty value differs from their default value
+func (c *Cmd) FlagAllowEmpty(name, value string) *Cmd {
+	return c.add("--"+name, Word(value))
+}
+
+// IntFlag adds a "--name value" flag, if the value is not zero
+func (c *Cmd) IntFlag(name string, value int) *Cmd {
+	if value == 0 {
+		return c
+	}
+	return c.add("--"+name, strconv.Itoa(value))
+}
+
+// BoolFlag adds a "--name" flag, if the value is true
+func (c *Cmd) BoolFlag(name string, value bool) *Cmd {
+	if !value {
+		return c
+	}
+	return c.add("--" + name)
+}
+
+// DisableFlag adds a "--name=false" flag, to disable an option enabled by default, if the value is false
+func (c *Cmd) DisableFlag(name string, value bool) *Cmd {
+	if value {
+		return c
+	}
+	return c.add("--" + name + "=false")
+}
+
+// ListFlag adds a "--name values" flag, if there are values
+func (c *Cmd) ListFlag(name string, values []string) *Cmd {
+	if len(values) == 0 {
+		return c
+	}
+	return c.add("--"+name, listWord(values))
+}
+
+// RefFlag adds a "--name ref" flag for a reference returned by Var or Sub, if the reference is not empty
+func (c *Cmd) RefFlag(name, ref string) *Cmd {
+	if ref == "" {
+		return c
+	}
+	return c.add("--"+name, ref)
+}
+
+func (c *Cmd) String() string {
+	stages := make([]string, 0, len(c.stages))
+	for _, stage := range c.stages {
+		stages = append(stages, strings.Join(stage, " "))
+	}
+	return strings.Join(stages, " | ")
+}
+
+// Script is a dagger shell script
+type Script struct {
+	lines []string
+}
+
+// Run adds a line running the given command
+func (s *Script) Run(cmd *Cmd) *Script {
+	s.lines = append(s.lines, cmd.String())
+	return s
+}
+
+// Assign adds a line assigning the result of the given command to a variable: name=$(cmd)
+func (s *Script) Assign(name string, cmd *Cmd) *Script {
+	s.lines = append(s.lines, Ident(name)+"="+Sub(cmd))
+	return s
+}
+
+// Echo adds a line printing the given message - or an empty line
+func (s *Script) Echo(message string) *Script {
+	if message == "" {
+		s.lines = append(s.lines, ".echo")
+		return s
+	}
+	s.lines = append(s.lines, ".echo -n "+Quote(message))
+	return s
+}
+
+// Append adds the lines of another script
+func (s *Script) Append(other *Script) *Script {
+	s.lines = append(s.lines, other.lines...)
+	return s
+}
+
+func (s *Script) String() string {
+	if len(s.lines) == 0 {
+		return ""
+	}
+	return strings.Join(s.lines, "\n") + "\n"
+}
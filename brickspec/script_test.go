@@ -0,0 +1,141 @@
+package brickspec
+
+import (
+	"encoding/csv"
+	"math/rand"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// hostileString generates strings made of shell and CSV special characters
+type hostileString string
+
+const hostileChars = "abc XYZ 019 '\"`$(){}[]<>|&;*?~!#%^=,.-_/\\\n\t\r é🧱"
+
+func (hostileString) Generate(rand *rand.Rand, size int) reflect.Value {
+	chars := []rune(hostileChars)
+	runes := make([]rune, rand.Intn(size+1))
+	for i := range runes {
+		runes[i] = chars[rand.Intn(len(chars))]
+	}
+	return reflect.ValueOf(hostileString(runes))
+}
+
+func hostileStrings(values []hostileString) []string {
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		strs = append(strs, string(value))
+	}
+	return strs
+}
+
+// shellWords returns the words of the given command line, as parsed by a POSIX shell
+func shellWords(t *testing.T, cmdLine string) []string {
+	t.Helper()
+	output, err := exec.Command("sh", "-c", `printf '%s\0' `+cmdLine).Output()
+	if err != nil {
+		t.Fatalf("failed to run %q: %v", cmdLine, err)
+	}
+	return strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00")
+}
+
+func requireShell(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+}
+
+func TestQuote(t *testing.T) {
+	requireShell(t)
+	property := func(value hostileString) bool {
+		words := shellWords(t, Quote(string(value)))
+		return len(words) == 1 && words[0] == string(value)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 200}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestList(t *testing.T) {
+	requireShell(t)
+	property := func(values []hostileString) bool {
+		if len(values) == 0 {
+			return true
+		}
+		words := shellWords(t, List(hostileStrings(values)))
+		if len(words) != 1 {
+			return false
+		}
+		records, err := csv.NewReader(strings.NewReader(words[0])).ReadAll()
+		if err != nil || len(records) != 1 {
+			// a single empty value is written as an empty line by the CSV writer
+			return len(values) == 1 && values[0] == "" && words[0] == ""
+		}
+		// CSV readers normalize the line breaks inside quoted values
+		expected := hostileStrings(values)
+		for i := range expected {
+			expected[i] = strings.ReplaceAll(expected[i], "\r\n", "\n")
+		}
+		return reflect.DeepEqual(records[0], expected)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 200}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCmdFlags(t *testing.T) {
+	requireShell(t)
+	property := func(arg, flag hostileString) bool {
+		// the arguments are interpolated
+		cmd := Command("cmd").Arg(EscapeExpressions(string(arg))).
+			Flag("flag", EscapeExpressions(string(flag))).
+			FlagAllowEmpty("empty", EscapeExpressions(string(flag)))
+		expected := []string{"cmd", string(arg)}
+		if flag != "" {
+			expected = append(expected, "--flag", string(flag))
+		}
+		expected = append(expected, "--empty", string(flag))
+		return reflect.DeepEqual(shellWords(t, cmd.String()), expected)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 200}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIdent(t *testing.T) {
+	property := func(name hostileString) bool {
+		ident := Ident(string(name))
+		return identifierRegexp.MatchString(ident) && Var(string(name)) == "$"+ident
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+	if ident := Ident("my_binary"); ident != "my_binary" {
+		t.Errorf("valid identifiers must not be changed, got %q", ident)
+	}
+}
+
+func TestScript(t *testing.T) {
+	var script Script
+	script.
+		Assign("binary", Command("./golang").
+			Flag("source", "").
+			RefFlag("source", Sub(Command("host").Pipe("directory", "my dir"))).
+			Pipe("build-binary").
+			ListFlag("args", []string{"-ldflags=-s -w", "./cmd/..."}).
+			BoolFlag("cover", true)).
+		Run(FromRef(Var("binary")).Pipe("export", "out/it's.bin")).
+		Echo("")
+
+	expected := `binary=$(./golang --source $(host | directory 'my dir') | build-binary --args '-ldflags=-s -w,./cmd/...' --cover)
+$binary | export 'out/it'\''s.bin'
+.echo
+`
+	if actual := script.String(); actual != expected {
+		t.Errorf("unexpected script:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
@@ -0,0 +1,149 @@
+package brickspec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Validator can be implemented by the specs
+// to add validation rules which can't be expressed with struct tags.
+// The returned errors should be FieldErrors, with paths relative to the spec.
+type Validator interface {
+	Validate() error
+}
+
+// ApplyDefaults sets the default values of the zero fields of the given struct pointer,
+// from their `default` struct tag.
+// The tag value is parsed according to the type of the field:
+// as-is for strings, and as JSON for the other types, such as `default:"true"` or `default:"[\"./...\"]"`.
+func ApplyDefaults(v any) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return fmt.Errorf("cannot apply defaults to %T: must be a non-nil pointer", v)
+	}
+	return applyDefaults(value.Elem(), "")
+}
+
+func applyDefaults(value reflect.Value, path string) error {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if !value.IsNil() {
+			return applyDefaults(value.Elem(), path)
+		}
+	case reflect.Slice, reflect.Array:
+		var errs []error
+		for i := range value.Len() {
+			errs = append(errs, applyDefaults(value.Index(i), fmt.Sprintf("%s[%d]", path, i)))
+		}
+		return errors.Join(errs...)
+	case reflect.Struct:
+		var errs []error
+		for _, field := range reflect.VisibleFields(value.Type()) {
+			if !field.IsExported() || field.Anonymous {
+				continue
+			}
+			fieldValue := value.FieldByIndex(field.Index)
+			fieldPath := joinPath(path, jsonName(field))
+			if defaultValue, ok := field.Tag.Lookup("default"); ok && fieldValue.IsZero() {
+				if err := setDefault(fieldValue, defaultValue); err != nil {
+					errs = append(errs, Errorf(fieldPath, "invalid default value %q: %v", defaultValue, err))
+					continue
+				}
+			}
+			errs = append(errs, applyDefaults(fieldValue, fieldPath))
+		}
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+func setDefault(value reflect.Value, defaultValue string) error {
+	if value.Kind() == reflect.String {
+		value.SetString(defaultValue)
+		return nil
+	}
+	if value.Kind() == reflect.Pointer && value.Type().Elem().Kind() == reflect.String {
+		value.Set(reflect.New(value.Type().Elem()))
+		value.Elem().SetString(defaultValue)
+		return nil
+	}
+	ptr := reflect.New(value.Type())
+	if err := json.Unmarshal([]byte(defaultValue), ptr.Interface()); err != nil {
+		return err
+	}
+	value.Set(ptr.Elem())
+	return nil
+}
+
+// Validate validates the given struct pointer, using the following struct tags:
+//   - `validate:"required"` for fields which must not be empty
+//   - `validate:"identifier"` for string fields which must be valid dagger shell variable names, when set
+//   - `enum:"a,b,c"` for string fields which must have one of the given values, when set
+//
+// The ${namespace.key} expressions of all the strings are validated too: see Word.
+// Nested structs are only validated when they are set - or required.
+// If the value implements the Validator interface, its own validation rules are applied too.
+func Validate(v any) error {
+	value := reflect.ValueOf(v)
+	errs := validate(value, "")
+	if validator, ok := v.(Validator); ok {
+		errs = append(errs, validator.Validate())
+	}
+	return errors.Join(errs...)
+}
+
+func validate(value reflect.Value, path string) []error {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !value.IsNil() {
+			return validate(value.Elem(), path)
+		}
+	case reflect.Slice, reflect.Array:
+		var errs []error
+		for i := range value.Len() {
+			errs = append(errs, validate(value.Index(i), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return errs
+	case reflect.Struct:
+		var errs []error
+		for _, field := range reflect.VisibleFields(value.Type()) {
+			if !field.IsExported() || field.Anonymous {
+				continue
+			}
+			fieldValue := value.FieldByIndex(field.Index)
+			fieldPath := joinPath(path, jsonName(field))
+			rules := strings.Split(field.Tag.Get("validate"), ",")
+			required := slices.Contains(rules, "required")
+			if required && fieldValue.IsZero() {
+				errs = append(errs, Errorf(fieldPath, "is required"))
+				continue
+			}
+			if slices.Contains(rules, "identifier") && fieldValue.Kind() == reflect.String {
+				if s := fieldValue.String(); s != "" && !identifierRegexp.MatchString(s) {
+					errs = append(errs, Errorf(fieldPath, "invalid value %s: must be a valid variable name (%s)",
+						strconv.Quote(s), identifierRegexp))
+				}
+			}
+			if enum, ok := field.Tag.Lookup("enum"); ok && fieldValue.Kind() == reflect.String {
+				values := strings.Split(enum, ",")
+				if s := fieldValue.String(); s != "" && !slices.Contains(values, s) {
+					errs = append(errs, Errorf(fieldPath, "invalid value %s: must be one of %s",
+						strconv.Quote(s), strings.Join(values, ", ")))
+				}
+			}
+			if fieldValue.Kind() == reflect.Struct && fieldValue.IsZero() && !required {
+				continue
+			}
+			errs = append(errs, validate(fieldValue, fieldPath)...)
+		}
+		return errs
+	case reflect.String:
+		return interpolationErrors(value.String(), path)
+	}
+	return nil
+}
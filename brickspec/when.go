@@ -0,0 +1,123 @@
+package brickspec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// whenField is the name of the spec field holding the conditions of a brick, supported by all the kinds
+const whenField = "when"
+
+// When are the conditions of a brick: it only runs if all the given conditions are met.
+// The brick is skipped with a logged reason otherwise.
+type When struct {
+	Paths            []string `json:"paths" description:"Glob patterns of the paths - with ** for any number of directories. At least one changed file must match."`
+	Branches         []string `json:"branches" description:"Glob patterns of the branch names. The current branch - or tag - must match."`
+	Tags             []string `json:"tags" description:"Glob patterns of the tag names. The current tag - or branch - must match."`
+	Env              []string `json:"env" description:"Names of the environment variables which must be set."`
+	DiffTarget       string   `json:"diffTarget" default:"origin" description:"Git reference the changed files are compared to."`
+	FetchMissingRefs bool     `json:"fetchMissingRefs" description:"Fetch the missing refs - and deepen the shallow clones - when a remote is reachable, to check the conditions and resolve the git expressions of the spec, such as in the CI checkouts."`
+	CIEnv            []string `json:"ciEnv" description:"Environment variables of the CI, as KEY=VALUE, to find the branch name of a detached HEAD - default to the branch variables of the common CI systems, from the host."`
+}
+
+func (w When) Validate() error {
+	var errs []error
+	for i, name := range w.Env {
+		if !identifierRegexp.MatchString(name) {
+			errs = append(errs, Errorf(fmt.Sprintf("env[%d]", i), "invalid value %q: must be a valid environment variable name", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (w When) enabled() bool {
+	return len(w.Paths) > 0 || len(w.Branches) > 0 || len(w.Tags) > 0 || len(w.Env) > 0
+}
+
+// String returns a short description of the conditions
+func (w When) String() string {
+	var conditions []string
+	for _, condition := range []struct {
+		name   string
+		values []string
+	}{
+		{"paths", w.Paths},
+		{"branches", w.Branches},
+		{"tags", w.Tags},
+		{"env", w.Env},
+	} {
+		if len(condition.values) > 0 {
+			conditions = append(conditions, condition.name+" "+strings.Join(condition.values, ", "))
+		}
+	}
+	return strings.Join(conditions, "; ")
+}
+
+// splitWhen extracts the conditions from the raw spec of a brick,
+// and returns the conditions and the rest of the spec
+func splitWhen(rawSpec json.RawMessage) (When, json.RawMessage, error) {
+	var when When
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(rawSpec, &fields); err != nil || fields == nil {
+		// not an object: let the spec decoding report it
+		return when, rawSpec, nil
+	}
+
+	var rawWhen json.RawMessage
+	for key, value := range fields {
+		if strings.EqualFold(key, whenField) {
+			rawWhen = value
+			delete(fields, key)
+		}
+	}
+	if rawWhen == nil {
+		return when, rawSpec, nil
+	}
+	if err := DecodeSpec(rawWhen, &when); err != nil {
+		return when, nil, errors.Join(withFile(err, "", whenField)...)
+	}
+
+	rest, err := json.Marshal(fields)
+	if err != nil {
+		return when, nil, err
+	}
+	return when, rest, nil
+}
+
+// wrap returns the given script, only run if the conditions are met.
+// The git conditions are checked by the mason-git-info module.
+func (w When) wrap(script, brickName string) string {
+	if !w.enabled() || script == "" {
+		return script
+	}
+
+	const reasonVar = "skip_reason"
+	var buf bytes.Buffer
+	if len(w.Paths) > 0 || len(w.Branches) > 0 || len(w.Tags) > 0 {
+		check := FromRef(Var(gitInfoVar)).
+			Pipe("skip-reason").
+			ListFlag("paths", w.Paths).
+			ListFlag("branches", w.Branches).
+			ListFlag("tags", w.Tags)
+		if len(w.Paths) > 0 {
+			check.Flag("diff-target", w.DiffTarget)
+		}
+		buf.WriteString(new(Script).Assign(reasonVar, check).String())
+	} else {
+		buf.WriteString(reasonVar + "=''\n")
+	}
+	for _, name := range w.Env {
+		fmt.Fprintf(&buf, "if [ -z \"${%s+set}\" ]; then %s=%s; fi\n",
+			name, reasonVar, Quote("environment variable "+name+" is not set"))
+	}
+	fmt.Fprintf(&buf, "if [ -n \"$%s\" ]; then\n", reasonVar)
+	fmt.Fprintf(&buf, "  .echo %s\"$%s\"\n", Quote("Skipping "+brickName+": "), reasonVar)
+	buf.WriteString("else\n")
+	// the script is not indented, as it may contain multi-line quoted values
+	buf.WriteString(script)
+	buf.WriteString("fi\n")
+	return buf.String()
+}
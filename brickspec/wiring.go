@@ -0,0 +1,171 @@
+package brickspec
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// postRunPhase is the phase of the post-run bricks, which run after all the other phases
+const postRunPhase = "postrun"
+
+// BrickWiring is how a brick is wired to the other bricks of a blueprint:
+// the phases it runs in, and the dagger variables it produces and consumes
+type BrickWiring struct {
+	File     string   `json:"file"`
+	Kind     string   `json:"kind"`
+	Phases   []string `json:"phases"`
+	Produces []string `json:"produces,omitempty"`
+	Consumes []string `json:"consumes,omitempty"`
+	// When describes the conditions of the brick - empty if it always runs
+	When string `json:"when,omitempty"`
+}
+
+// Wiring returns the wiring of the bricks of the given blueprint files, sorted by file name,
+// from the artifacts declared by their specs.
+func (r *Registry[B]) Wiring(files []BlueprintFile) ([]BrickWiring, error) {
+	bricks, err := r.render(files)
+	if err != nil {
+		return nil, err
+	}
+
+	wirings := make([]BrickWiring, 0, len(bricks))
+	for _, brick := range bricks {
+		artifacts := brick.artifacts()
+		planFiles := make([]string, 0, len(brick.plan))
+		for name := range brick.plan {
+			planFiles = append(planFiles, name)
+		}
+		wirings = append(wirings, BrickWiring{
+			File:     brick.file,
+			Kind:     brick.kind,
+			Phases:   planPhases(planFiles),
+			Produces: artifacts.Produces,
+			Consumes: artifacts.Consumes,
+			When:     brick.when.String(),
+		})
+	}
+	return wirings, nil
+}
+
+// ValidateWiring validates the graph of the given bricks - usually from all the modules of a blueprint:
+//   - each consumed variable must be produced by a brick
+//   - each variable must be produced by a single brick
+//   - a variable produced by a conditional brick - which may be skipped - must be consumed
+//     by bricks with the same conditions
+//   - if the ordered phases are given, a variable must be produced in a phase
+//     which runs before - or with - each phase it is consumed in.
+//     The post-run bricks run after all the phases.
+//
+// All the errors are returned at once, as FieldErrors.
+func ValidateWiring(wirings []BrickWiring, phases []string) error {
+	producers := make(map[string]BrickWiring)
+	var errs []error
+	for _, wiring := range wirings {
+		for _, name := range wiring.Produces {
+			if producer, ok := producers[name]; ok {
+				errs = append(errs, &FieldError{
+					File:    wiring.File,
+					Message: fmt.Sprintf("variable %q is already produced by %s", name, producer.File),
+				})
+				continue
+			}
+			producers[name] = wiring
+		}
+	}
+
+	for _, wiring := range wirings {
+		for _, name := range wiring.Consumes {
+			producer, ok := producers[name]
+			if !ok {
+				errs = append(errs, &FieldError{
+					File:    wiring.File,
+					Message: fmt.Sprintf("variable %q is not produced by any brick%s", name, suggestion(name, producers)),
+				})
+				continue
+			}
+			if producer.When != "" && wiring.When != producer.When {
+				consumed := "without these conditions"
+				if wiring.When != "" {
+					consumed = "when " + wiring.When
+				}
+				errs = append(errs, &FieldError{
+					File: wiring.File,
+					Message: fmt.Sprintf("variable %q is only produced by %s when %s, but consumed %s",
+						name, producer.File, producer.When, consumed),
+				})
+			}
+			if len(phases) == 0 {
+				continue
+			}
+			firstPhase, firstIndex := firstPhase(producer.Phases, phases)
+			for _, phase := range wiring.Phases {
+				if index := phaseIndex(phase, phases); index >= 0 && firstIndex >= 0 && index < firstIndex {
+					errs = append(errs, &FieldError{
+						File: wiring.File,
+						Message: fmt.Sprintf("variable %q is consumed in phase %q, before it is produced by %s in phase %q",
+							name, phase, producer.File, firstPhase),
+					})
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// firstPhase returns the first of the given phases to run, and its index - or -1 if none of them is known
+func firstPhase(brickPhases, phases []string) (string, int) {
+	first, firstIndex := "", -1
+	for _, phase := range brickPhases {
+		if index := phaseIndex(phase, phases); index >= 0 && (firstIndex < 0 || index < firstIndex) {
+			first, firstIndex = phase, index
+		}
+	}
+	return first, firstIndex
+}
+
+// phaseIndex returns the position of the given phase in the ordered phases - or -1 if it is unknown
+func phaseIndex(phase string, phases []string) int {
+	if phase == postRunPhase {
+		return len(phases)
+	}
+	return slices.Index(phases, phase)
+}
+
+// suggestion returns a hint about the produced variable closest to the given name, if any
+func suggestion(name string, producers map[string]BrickWiring) string {
+	best, bestDistance := "", 3
+	for produced := range producers {
+		distance := editDistance(strings.ToLower(name), strings.ToLower(produced))
+		if distance < bestDistance || (distance == bestDistance && produced < best) {
+			best, bestDistance = produced, distance
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q produced by %s?", best, producers[best].File)
+}
+
+// editDistance returns the Levenshtein distance between the given strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
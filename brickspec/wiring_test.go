@@ -0,0 +1,97 @@
+package brickspec
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type testBrick struct {
+	Kind      string          `json:"kind"`
+	ModuleRef string          `json:"moduleRef"`
+	Name      string          `json:"name"`
+	Spec      json.RawMessage `json:"spec"`
+}
+
+type testSpec struct {
+	Phases   []string `json:"phases"`
+	Produces []string `json:"produces"`
+	Consumes []string `json:"consumes"`
+}
+
+func (s testSpec) Artifacts() Artifacts {
+	return Artifacts{Produces: s.Produces, Consumes: s.Consumes}
+}
+
+func (s testSpec) Plan(brick testBrick) map[string]string {
+	plan := make(map[string]string)
+	for _, phase := range s.Phases {
+		plan[phase+"_"+brick.Name] = ""
+	}
+	return plan
+}
+
+func newTestRegistry() *Registry[testBrick] {
+	registry := NewRegistry(func(brick testBrick) (string, string, json.RawMessage) {
+		return brick.Kind, brick.ModuleRef, brick.Spec
+	})
+	Register(registry, "test", testSpec.Plan)
+	return registry
+}
+
+func TestValidateWiring(t *testing.T) {
+	registry := newTestRegistry()
+
+	wirings, err := registry.Wiring([]BlueprintFile{
+		{Name: "build.json", Content: []byte(`{"kind": "test", "name": "build", "spec": {"phases": ["package"], "produces": ["app_binary"]}}`)},
+		{Name: "lint.json", Content: []byte(`{"kind": "test", "name": "lint", "spec": {"phases": ["lint"], "produces": ["app_binary"]}}`)},
+		{Name: "early.json", Content: []byte(`{"kind": "test", "name": "early", "spec": {"phases": ["test", "run"], "consumes": ["app_binary"]}}`)},
+		{Name: "run.json", Content: []byte(`{"kind": "test", "name": "run", "spec": {"phases": ["run"], "consumes": ["app_binary", "app_binray", "other"]}}`)},
+		{Name: "debug.json", Content: []byte(`{"kind": "test", "name": "debug", "spec": {"phases": ["postrun"], "consumes": ["app_binary"]}}`)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = ValidateWiring(wirings, []string{"lint", "test", "package", "run"})
+	expected := []string{
+		`lint.json: variable "app_binary" is already produced by build.json`,
+		`early.json: variable "app_binary" is consumed in phase "test", before it is produced by build.json in phase "package"`,
+		`run.json: variable "app_binray" is not produced by any brick, did you mean "app_binary" produced by build.json?`,
+		`run.json: variable "other" is not produced by any brick`,
+	}
+	if err == nil || err.Error() != strings.Join(expected, "\n") {
+		t.Errorf("unexpected error:\n%v\nexpected:\n%s", err, strings.Join(expected, "\n"))
+	}
+
+	if err := ValidateWiring(wirings[:1], nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateWiringConditions(t *testing.T) {
+	registry := newTestRegistry()
+
+	wirings, err := registry.Wiring([]BlueprintFile{
+		{Name: "build.json", Content: []byte(`{"kind": "test", "name": "build", "spec": {"phases": ["package"], "produces": ["app_binary"], "when": {"paths": ["cmd/**"]}}}`)},
+		{Name: "lint.json", Content: []byte(`{"kind": "test", "name": "lint", "spec": {"phases": ["lint"], "produces": ["lint_report"]}}`)},
+		{Name: "run.json", Content: []byte(`{"kind": "test", "name": "run", "spec": {"phases": ["run"], "consumes": ["app_binary", "lint_report"]}}`)},
+		{Name: "deploy.json", Content: []byte(`{"kind": "test", "name": "deploy", "spec": {"phases": ["run"], "consumes": ["app_binary"], "when": {"paths": ["cmd/**"], "env": ["CI"]}}}`)},
+		{Name: "publish.json", Content: []byte(`{"kind": "test", "name": "publish", "spec": {"phases": ["run"], "consumes": ["app_binary", "lint_report"], "when": {"paths": ["cmd/**"]}}}`)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wirings[0].When != "paths cmd/**" {
+		t.Errorf("unexpected conditions: %q", wirings[0].When)
+	}
+
+	err = ValidateWiring(wirings, nil)
+	expected := []string{
+		`deploy.json: variable "app_binary" is only produced by build.json when paths cmd/**, but consumed when paths cmd/**; env CI`,
+		`run.json: variable "app_binary" is only produced by build.json when paths cmd/**, but consumed without these conditions`,
+	}
+	if err == nil || err.Error() != strings.Join(expected, "\n") {
+		t.Errorf("unexpected error:\n%v\nexpected:\n%s", err, strings.Join(expected, "\n"))
+	}
+}
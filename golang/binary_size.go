@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"dagger/golang/internal/dagger"
+)
+
+const (
+	binarySizeFilePath = "/binary"
+)
+
+// BinarySize analyzes the size of a binary built by BuildBinary,
+// by per-package and per-symbol contribution - in the style of "go tool nm -size".
+func (g *Golang) BinarySize(
+	ctx context.Context,
+	// the binary to analyze
+	binary *dagger.File,
+	// a previous JSON report, to compare the binary against
+	// +optional
+	baseline *dagger.File,
+	// maximum size of the binary file, in bytes
+	// +optional
+	maxBytes int,
+	// maximum growth of the binary file compared to the baseline, in percent
+	// +optional
+	maxIncreasePercent int,
+	// number of largest symbols to include in the report
+	// +optional
+	// +default=50
+	topSymbols int,
+	// +optional
+	baseContainer *dagger.Container,
+) (*BinarySize, error) {
+	name, err := binary.Name(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fileSize, err := binary.Size(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctr := baseContainer
+	if ctr == nil {
+		ctr = g.BaseBuildContainer()
+	}
+	nmOutput, err := ctr.
+		WithFile(binarySizeFilePath, binary).
+		WithExec([]string{"go", "tool", "nm", "-size", "-sort", "size", binarySizeFilePath}).
+		Stdout(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := newBinarySizeReport(name, fileSize, parseNmSymbols(nmOutput), topSymbols)
+
+	result := &BinarySize{
+		Report:             report,
+		MaxBytes:           maxBytes,
+		MaxIncreasePercent: maxIncreasePercent,
+	}
+	if baseline != nil {
+		data, err := baseline.Contents(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &result.Baseline); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal baseline report: %w", err)
+		}
+		result.HasBaseline = true
+	}
+
+	return result, nil
+}
+
+type BinarySize struct {
+	Report             BinarySizeReport
+	Baseline           BinarySizeReport
+	HasBaseline        bool
+	MaxBytes           int
+	MaxIncreasePercent int
+}
+
+type BinarySizeReport struct {
+	Binary      string                    `json:"binary"`
+	FileSize    int                       `json:"fileSize"`
+	SymbolsSize int                       `json:"symbolsSize"`
+	Packages    []BinarySizeReportPackage `json:"packages"`
+	Symbols     []BinarySizeReportSymbol  `json:"symbols"`
+}
+
+type BinarySizeReportPackage struct {
+	Name    string `json:"name"`
+	Size    int    `json:"size"`
+	Symbols int    `json:"symbols"`
+}
+
+type BinarySizeReportSymbol struct {
+	Name    string `json:"name"`
+	Package string `json:"package"`
+	Type    string `json:"type"`
+	Size    int    `json:"size"`
+}
+
+// Assert fails if the binary exceeds the configured size budget
+func (b *BinarySize) Assert() (string, error) {
+	summary := fmt.Sprintf("%s: %s", b.Report.Binary, formatBytes(b.Report.FileSize))
+	if b.HasBaseline {
+		summary += fmt.Sprintf(" (%s compared to baseline)", formatBytesDelta(b.Report.FileSize-b.Baseline.FileSize))
+	}
+
+	if b.MaxBytes > 0 && b.Report.FileSize > b.MaxBytes {
+		return summary, fmt.Errorf("binary %s is %s, which exceeds the size budget of %s",
+			b.Report.Binary, formatBytes(b.Report.FileSize), formatBytes(b.MaxBytes))
+	}
+	if b.MaxIncreasePercent > 0 && b.HasBaseline && b.Baseline.FileSize > 0 {
+		increase := float64(b.Report.FileSize-b.Baseline.FileSize) * 100 / float64(b.Baseline.FileSize)
+		if increase > float64(b.MaxIncreasePercent) {
+			return summary, fmt.Errorf("binary %s grew by %.1f%% compared to the baseline, which exceeds the budget of %d%%",
+				b.Report.Binary, increase, b.MaxIncreasePercent)
+		}
+	}
+	return summary, nil
+}
+
+func (b *BinarySize) JSONFile() (*dagger.File, error) {
+	data, err := json.MarshalIndent(b.Report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return dag.File("binary-size.json", string(data)), nil
+}
+
+func (b *BinarySize) Markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Binary size: %s\n\n", b.Report.Binary)
+	fmt.Fprintf(&sb, "- File size: %s\n", formatBytes(b.Report.FileSize))
+	fmt.Fprintf(&sb, "- Symbols size: %s\n", formatBytes(b.Report.SymbolsSize))
+	if b.HasBaseline {
+		fmt.Fprintf(&sb, "- Baseline file size: %s (%s)\n",
+			formatBytes(b.Baseline.FileSize), formatBytesDelta(b.Report.FileSize-b.Baseline.FileSize))
+	}
+	if b.MaxBytes > 0 {
+		fmt.Fprintf(&sb, "- Size budget: %s\n", formatBytes(b.MaxBytes))
+	}
+
+	baselinePackages := make(map[string]int, len(b.Baseline.Packages))
+	for _, pkg := range b.Baseline.Packages {
+		baselinePackages[pkg.Name] = pkg.Size
+	}
+
+	sb.WriteString("\n## Packages\n\n")
+	if b.HasBaseline {
+		sb.WriteString("| Package | Size | Symbols | Delta |\n|---|---:|---:|---:|\n")
+	} else {
+		sb.WriteString("| Package | Size | Symbols |\n|---|---:|---:|\n")
+	}
+	for _, pkg := range b.Report.Packages {
+		fmt.Fprintf(&sb, "| `%s` | %s | %d |", pkg.Name, formatBytes(pkg.Size), pkg.Symbols)
+		if b.HasBaseline {
+			fmt.Fprintf(&sb, " %s |", formatBytesDelta(pkg.Size-baselinePackages[pkg.Name]))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n## Largest symbols\n\n")
+	sb.WriteString("| Symbol | Type | Size |\n|---|---|---:|\n")
+	for _, sym := range b.Report.Symbols {
+		fmt.Fprintf(&sb, "| `%s` | %s | %s |\n", sym.Name, sym.Type, formatBytes(sym.Size))
+	}
+
+	return sb.String()
+}
+
+func (b *BinarySize) MarkdownFile() *dagger.File {
+	return dag.File("binary-size.md", b.Markdown())
+}
+
+func (b *BinarySize) Reports() (*dagger.Directory, error) {
+	jsonFile, err := b.JSONFile()
+	if err != nil {
+		return nil, err
+	}
+	return dag.Directory().
+		WithFile("binary-size.json", jsonFile).
+		WithFile("binary-size.md", b.MarkdownFile()), nil
+}
+
+func newBinarySizeReport(name string, fileSize int, symbols []BinarySizeReportSymbol, topSymbols int) BinarySizeReport {
+	report := BinarySizeReport{
+		Binary:   name,
+		FileSize: fileSize,
+	}
+
+	packages := make(map[string]*BinarySizeReportPackage)
+	for _, sym := range symbols {
+		report.SymbolsSize += sym.Size
+		pkg, ok := packages[sym.Package]
+		if !ok {
+			pkg = &BinarySizeReportPackage{Name: sym.Package}
+			packages[sym.Package] = pkg
+		}
+		pkg.Size += sym.Size
+		pkg.Symbols++
+	}
+	for _, pkg := range packages {
+		report.Packages = append(report.Packages, *pkg)
+	}
+	slices.SortFunc(report.Packages, func(a, b BinarySizeReportPackage) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), cmp.Compare(a.Name, b.Name))
+	})
+
+	slices.SortStableFunc(symbols, func(a, b BinarySizeReportSymbol) int {
+		return cmp.Compare(b.Size, a.Size)
+	})
+	if topSymbols > 0 && len(symbols) > topSymbols {
+		symbols = symbols[:topSymbols]
+	}
+	report.Symbols = symbols
+
+	return report
+}
+
+// parseNmSymbols parses the output of "go tool nm -size",
+// which is made of lines in the format "address size type name".
+// Undefined symbols and BSS symbols are ignored, because they don't take space in the binary file.
+func parseNmSymbols(output string) []BinarySizeReportSymbol {
+	var symbols []BinarySizeReportSymbol
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		size, err := strconv.Atoi(fields[1])
+		if err != nil || size == 0 {
+			continue
+		}
+		symType := fields[2]
+		switch symType {
+		case "U", "B", "b":
+			continue
+		}
+		name := strings.Join(fields[3:], " ")
+		symbols = append(symbols, BinarySizeReportSymbol{
+			Name:    name,
+			Package: symbolPackage(name),
+			Type:    symType,
+			Size:    size,
+		})
+	}
+	return symbols
+}
+
+// symbolPackage returns the import path of the package defining the given symbol,
+// such as "github.com/foo/bar" for "github.com/foo/bar.(*Baz).Method".
+func symbolPackage(name string) string {
+	for _, prefix := range []string{"type:", "go:itab.", "go:", "*"} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	// generic instantiations may contain other import paths
+	if i := strings.IndexAny(name, "[("); i >= 0 {
+		name = name[:i]
+	}
+	lastSlash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[lastSlash+1:], ".")
+	if dot < 0 {
+		return "<other>"
+	}
+	return name[:lastSlash+1+dot]
+}
+
+func formatBytes(size int) string {
+	const unit = 1024
+	if size < unit && size > -unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exp := float64(size)/unit, 0
+	for value >= unit || value <= -unit {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[exp])
+}
+
+func formatBytesDelta(delta int) string {
+	if delta > 0 {
+		return "+" + formatBytes(delta)
+	}
+	return formatBytes(delta)
+}
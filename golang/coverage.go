@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"dagger/golang/internal/dagger"
+)
+
+const (
+	coverageDirPath         = "/output/coverage"
+	coverageUnitDirPath     = coverageDirPath + "/unit"
+	coverageMergedDirPath   = coverageDirPath + "/merged"
+	coverageProfileFilePath = coverageDirPath + "/coverage.out"
+)
+
+// Coverage runs the unit tests with coverage enabled,
+// and merges the resulting profiles with the ones written by instrumented binaries
+// (built with "cover" enabled and run with $GOCOVERDIR set).
+func (g *Golang) Coverage(
+	ctx context.Context,
+	// GOCOVERDIR directories collected from runs of instrumented binaries
+	// +optional
+	coverDirs []*dagger.Directory,
+	// "go test" extra arguments
+	// +optional
+	// +default=["./..."]
+	args []string,
+	// +optional
+	baseContainer *dagger.Container,
+) (*CoverageRun, error) {
+	ctr := g.Container(baseContainer).
+		WithDirectory("/src", g.Source).
+		WithWorkdir(filepath.Join("/src", g.Module)).
+		WithExec([]string{"mkdir", "-p", coverageUnitDirPath, coverageMergedDirPath})
+
+	testCmd := append([]string{"go", "test", "-cover"}, args...)
+	testCmd = append(testCmd, "-args", "-test.gocoverdir="+coverageUnitDirPath)
+	ctr = ctr.WithExec(testCmd)
+
+	inputDirs := []string{coverageUnitDirPath}
+	for i, coverDir := range coverDirs {
+		dirPath := fmt.Sprintf("%s/binary-%d", coverageDirPath, i)
+		ctr = ctr.WithDirectory(dirPath, coverDir)
+		inputDirs = append(inputDirs, dirPath)
+	}
+
+	ctr = ctr.
+		WithExec([]string{
+			"go", "tool", "covdata", "merge",
+			"-i=" + strings.Join(inputDirs, ","),
+			"-o=" + coverageMergedDirPath,
+		}).
+		WithExec([]string{
+			"go", "tool", "covdata", "textfmt",
+			"-i=" + coverageMergedDirPath,
+			"-o=" + coverageProfileFilePath,
+		})
+
+	if _, err := ctr.Sync(ctx); err != nil {
+		return nil, err
+	}
+
+	return &CoverageRun{
+		Ctr: ctr,
+	}, nil
+}
+
+// CoverageRun is the coverage data merged from the instrumented binaries
+type CoverageRun struct {
+	Ctr *dagger.Container
+}
+
+// Percent returns the per-package coverage percentages of the merged profiles
+func (c *CoverageRun) Percent(ctx context.Context) (string, error) {
+	output, err := c.Ctr.
+		WithExec([]string{"go", "tool", "covdata", "percent", "-i=" + coverageMergedDirPath}).
+		Stdout(ctx)
+	return strings.TrimSpace(output), err
+}
+
+// ProfileFile returns the merged coverage profile, in the legacy "go test -coverprofile" text format
+func (c *CoverageRun) ProfileFile() *dagger.File {
+	return c.Ctr.File(coverageProfileFilePath)
+}
+
+// Directory returns the merged coverage data, in the binary GOCOVERDIR format
+func (c *CoverageRun) Directory() *dagger.Directory {
+	return c.Ctr.Directory(coverageMergedDirPath)
+}
+
+// Reports returns the merged coverage profile as coverage.out, and the merged coverage data in the coverage directory
+func (c *CoverageRun) Reports() *dagger.Directory {
+	return dag.Directory().
+		WithFile("coverage.out", c.ProfileFile()).
+		WithDirectory("coverage", c.Directory())
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"dagger/golang/internal/dagger"
@@ -11,6 +13,8 @@ import (
 const (
 	baseRunImage   = "cgr.dev/chainguard/wolfi-base:latest"
 	baseBuildImage = "cgr.dev/chainguard/go:latest-dev"
+
+	macosSdkPath = "/macos-sdk"
 )
 
 type Golang struct {
@@ -92,35 +96,195 @@ func (g *Golang) BuildBinary(
 	// Default to "{os}_{arch}"
 	// +optional
 	outputFileName string,
+	// Build an instrumented binary ("go build -cover"),
+	// which writes coverage data to $GOCOVERDIR when run
+	// +optional
+	cover bool,
+	// CPU profile used for profile-guided optimization ("go build -pgo")
+	// See https://go.dev/doc/pgo
+	// +optional
+	pgoProfile *dagger.File,
+	// Enable cgo, using zig as a cross C toolchain for the target platform - linux, darwin or windows
+	// The base container must be able to install zig with apk
+	// +optional
+	cgo bool,
+	// C library to link against when cgo is enabled on linux:
+	// "musl" for a fully static binary, or "gnu" for a binary dynamically linked against glibc
+	// +optional
+	// +default="musl"
+	cgoLibc string,
+	// macOS SDK directory - such as MacOSX.sdk from Xcode - used as the sysroot when cgo is enabled on darwin.
+	// Without it, only the C library bundled with zig is available:
+	// it is required to link against the macOS frameworks, such as the ones used by crypto/x509
+	// +optional
+	macosSdk *dagger.Directory,
 	// +optional
 	baseContainer *dagger.Container,
 ) *dagger.File {
-	if goOs == "" || goArch == "" {
+	return g.buildBinary(ctx, binaryBuild{
+		goOs:           goOs,
+		goArch:         goArch,
+		args:           args,
+		outputFileName: outputFileName,
+		cover:          cover,
+		pgoProfile:     pgoProfile,
+		cgo:            cgo,
+		cgoLibc:        cgoLibc,
+		macosSdk:       macosSdk,
+		baseContainer:  baseContainer,
+	})
+}
+
+// binaryBuild are the options of BuildBinary
+type binaryBuild struct {
+	goOs, goArch   string
+	args           []string
+	outputFileName string
+	cover          bool
+	pgoProfile     *dagger.File
+	cgo            bool
+	cgoLibc        string
+	macosSdk       *dagger.Directory
+	baseContainer  *dagger.Container
+}
+
+// buildBinary builds a binary with the given options - the zero values are the defaults of BuildBinary,
+// except for cgoLibc, which is required with cgo
+func (g *Golang) buildBinary(ctx context.Context, b binaryBuild) *dagger.File {
+	if b.goOs == "" || b.goArch == "" {
 		defaultPlatform, _ := dag.DefaultPlatform(ctx) //nolint:errcheck // don't care
 		defaultOs, defaultArch, _ := extractPlatform(defaultPlatform)
-		if goOs == "" {
-			goOs = defaultOs
+		if b.goOs == "" {
+			b.goOs = defaultOs
 		}
-		if goArch == "" {
-			goArch = defaultArch
+		if b.goArch == "" {
+			b.goArch = defaultArch
 		}
 	}
 
-	if outputFileName == "" {
-		outputFileName = goOs + "_" + goArch
+	if b.outputFileName == "" {
+		b.outputFileName = b.goOs + "_" + b.goArch
 	}
-	outputFile := "/" + outputFileName
-	args = append([]string{"go", "build", "-o", outputFile}, args...)
+	outputFile := "/" + b.outputFileName
+	cmd := []string{"go", "build", "-o", outputFile}
+	if b.cover {
+		cmd = append(cmd, "-cover")
+	}
+	if b.pgoProfile != nil {
+		cmd = append(cmd, "-pgo="+pgoProfileFilePath)
+	}
+	args := b.args
+	if b.cgo && b.goOs == "linux" && b.cgoLibc == "musl" {
+		args = withLdflags(args, "-linkmode=external -extldflags=-static")
+	}
+	args = append(cmd, args...)
 
-	return g.Container(baseContainer).
+	ctr := g.Container(b.baseContainer)
+	if b.pgoProfile != nil {
+		ctr = ctr.WithFile(pgoProfileFilePath, b.pgoProfile)
+	}
+	if b.cgo {
+		ctr = withCgoToolchain(ctr, b)
+	}
+
+	return ctr.
 		WithDirectory("/src", g.Source, dagger.ContainerWithDirectoryOpts{
 			Exclude: []string{"**/*_test.go"},
 		}).
-		WithEnvVariable("GOOS", goOs).
-		WithEnvVariable("GOARCH", goArch).
+		WithEnvVariable("GOOS", b.goOs).
+		WithEnvVariable("GOARCH", b.goArch).
 		WithExec(args).
 		File(outputFile).
-		WithName(outputFileName)
+		WithName(b.outputFileName)
+}
+
+// withCgoToolchain returns the container with zig installed as the cross C toolchain of the build.
+// An unsupported platform fails the build, when the binary is evaluated.
+func withCgoToolchain(ctr *dagger.Container, b binaryBuild) *dagger.Container {
+	target, err := zigTarget(b.goOs, b.goArch, b.cgoLibc)
+	if err != nil {
+		return ctr.WithExec([]string{"sh", "-c", `echo "$1" >&2; exit 1`, "sh", err.Error()})
+	}
+
+	flags := ""
+	if b.goOs == "darwin" && b.macosSdk != nil {
+		ctr = ctr.WithMountedDirectory(macosSdkPath, b.macosSdk)
+		flags = " " + strings.Join(macosSdkFlags(macosSdkPath), " ")
+	}
+
+	return ctr.
+		WithExec([]string{"apk", "add", "--no-cache", "zig"}).
+		WithEnvVariable("ZIG_GLOBAL_CACHE_DIR", "/go/zig-cache").
+		WithMountedCache("/go/zig-cache", dag.CacheVolume("zig")).
+		WithEnvVariable("CGO_ENABLED", "1").
+		WithEnvVariable("CC", "zig cc -target "+target+flags).
+		WithEnvVariable("CXX", "zig c++ -target "+target+flags)
+}
+
+// zigTarget returns the zig target triple for the given Go platform
+func zigTarget(goOs, goArch, libc string) (string, error) {
+	arch, ok := map[string]string{
+		"amd64":   "x86_64",
+		"arm64":   "aarch64",
+		"386":     "x86",
+		"arm":     "arm",
+		"riscv64": "riscv64",
+		"ppc64le": "powerpc64le",
+		"s390x":   "s390x",
+	}[goArch]
+	if !ok {
+		return "", fmt.Errorf("unsupported architecture for cgo cross-compilation: %s", goArch)
+	}
+
+	switch goOs {
+	case "linux":
+		if libc != "musl" && libc != "gnu" {
+			return "", fmt.Errorf("unsupported C library for cgo cross-compilation: %q (must be musl or gnu)", libc)
+		}
+		if goArch == "arm" {
+			libc += "eabihf"
+		}
+		return arch + "-linux-" + libc, nil
+	case "darwin":
+		if goArch != "amd64" && goArch != "arm64" {
+			return "", fmt.Errorf("unsupported architecture for cgo cross-compilation to darwin: %s", goArch)
+		}
+		return arch + "-macos", nil
+	case "windows":
+		return arch + "-windows-gnu", nil
+	default:
+		return "", fmt.Errorf("unsupported OS for cgo cross-compilation: %s", goOs)
+	}
+}
+
+// macosSdkFlags returns the C compiler flags to use the macOS SDK at the given path as the sysroot,
+// with its headers, libraries and frameworks
+func macosSdkFlags(sdkPath string) []string {
+	return []string{
+		"--sysroot=" + sdkPath,
+		"-I" + sdkPath + "/usr/include",
+		"-L" + sdkPath + "/usr/lib",
+		"-F" + sdkPath + "/System/Library/Frameworks",
+	}
+}
+
+// withLdflags returns the given "go build" arguments with the given linker flags
+// prepended to their -ldflags argument - as only the last one is used by go build - or before them
+func withLdflags(args []string, ldflags string) []string {
+	args = slices.Clone(args)
+	for i := len(args) - 1; i >= 0; i-- {
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(args[i], "-"), "=")
+		if name != "-ldflags" && name != "ldflags" {
+			continue
+		}
+		if hasValue {
+			args[i] = "-ldflags=" + ldflags + " " + value
+		} else if i+1 < len(args) {
+			args[i+1] = ldflags + " " + args[i+1]
+		}
+		return args
+	}
+	return append([]string{"-ldflags=" + ldflags}, args...)
 }
 
 func extractPlatform(platform dagger.Platform) (os, arch string, ok bool) {
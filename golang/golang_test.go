@@ -0,0 +1,71 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestZigTarget(t *testing.T) {
+	for _, tc := range []struct {
+		goOs, goArch, libc string
+		expected           string
+		expectedErr        string
+	}{
+		{goOs: "linux", goArch: "amd64", libc: "musl", expected: "x86_64-linux-musl"},
+		{goOs: "linux", goArch: "arm64", libc: "gnu", expected: "aarch64-linux-gnu"},
+		{goOs: "linux", goArch: "arm", libc: "musl", expected: "arm-linux-musleabihf"},
+		{goOs: "linux", goArch: "arm", libc: "gnu", expected: "arm-linux-gnueabihf"},
+		{goOs: "linux", goArch: "386", libc: "musl", expected: "x86-linux-musl"},
+		{goOs: "linux", goArch: "riscv64", libc: "musl", expected: "riscv64-linux-musl"},
+		{goOs: "linux", goArch: "ppc64le", libc: "gnu", expected: "powerpc64le-linux-gnu"},
+		{goOs: "linux", goArch: "s390x", libc: "gnu", expected: "s390x-linux-gnu"},
+		{goOs: "windows", goArch: "amd64", expected: "x86_64-windows-gnu"},
+		{goOs: "windows", goArch: "arm64", libc: "musl", expected: "aarch64-windows-gnu"},
+		{goOs: "linux", goArch: "amd64", libc: "glibc", expectedErr: `unsupported C library for cgo cross-compilation: "glibc"`},
+		{goOs: "linux", goArch: "mips", libc: "musl", expectedErr: "unsupported architecture for cgo cross-compilation: mips"},
+		{goOs: "darwin", goArch: "arm64", expected: "aarch64-macos"},
+		{goOs: "darwin", goArch: "amd64", libc: "musl", expected: "x86_64-macos"},
+		{goOs: "darwin", goArch: "386", expectedErr: "unsupported architecture for cgo cross-compilation to darwin: 386"},
+		{goOs: "freebsd", goArch: "amd64", expectedErr: "unsupported OS for cgo cross-compilation: freebsd"},
+	} {
+		target, err := zigTarget(tc.goOs, tc.goArch, tc.libc)
+		switch {
+		case tc.expectedErr != "":
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+				t.Errorf("%s/%s %s: expected error %q, got %q, %v", tc.goOs, tc.goArch, tc.libc, tc.expectedErr, target, err)
+			}
+		case err != nil || target != tc.expected:
+			t.Errorf("%s/%s %s: expected %q, got %q, %v", tc.goOs, tc.goArch, tc.libc, tc.expected, target, err)
+		}
+	}
+}
+
+func TestWithLdflags(t *testing.T) {
+	const static = "-linkmode=external -extldflags=-static"
+	for _, tc := range []struct {
+		args     []string
+		expected []string
+	}{
+		{
+			args:     []string{"./cmd/app"},
+			expected: []string{"-ldflags=" + static, "./cmd/app"},
+		},
+		{
+			args:     []string{"-ldflags=-s -w", "./cmd/app"},
+			expected: []string{"-ldflags=" + static + " -s -w", "./cmd/app"},
+		},
+		{
+			args:     []string{"--ldflags", "-X main.version=1.0.0", "./cmd/app"},
+			expected: []string{"--ldflags", static + " -X main.version=1.0.0", "./cmd/app"},
+		},
+		{
+			args:     []string{"-ldflags=-s", "-trimpath", "-ldflags=-w", "./cmd/app"},
+			expected: []string{"-ldflags=-s", "-trimpath", "-ldflags=" + static + " -w", "./cmd/app"},
+		},
+	} {
+		if actual := withLdflags(tc.args, static); !slices.Equal(actual, tc.expected) {
+			t.Errorf("%q: expected %q, got %q", tc.args, tc.expected, actual)
+		}
+	}
+}
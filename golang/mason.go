@@ -3,70 +3,85 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"strings"
+	"maps"
+	"slices"
 
 	"dagger/golang/internal/dagger"
 
+	"github.com/vbehar/mason-modules/brickspec"
 	"github.com/vbehar/mason-sdk-go"
 )
 
+var bricks = newBrickRegistry()
+
+func newBrickRegistry() *brickspec.Registry[mason.Brick] {
+	registry := brickspec.NewRegistry(func(brick mason.Brick) (string, string, json.RawMessage) {
+		return brick.Kind, brick.ModuleRef, json.RawMessage(brick.Spec)
+	})
+	brickspec.Register(registry, "gobinary", GoBinarySpec.Plan)
+	brickspec.Register(registry, "gotest", GoTestSpec.Plan)
+	brickspec.Register(registry, "golint", GoLintSpec.Plan)
+	return registry
+}
+
+// RenderPlan renders the plan of the given blueprint: the dagger shell scripts of each phase
 func (m *Golang) RenderPlan(ctx context.Context, blueprint *dagger.Directory) (*dagger.Directory, error) {
-	fileNames, err := blueprint.Entries(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get blueprint directory entries: %w", err)
-	}
+	return newDirectory(bricks.RenderPlan(ctx, blueprintSource(blueprint)))
+}
+
+// ExplainPlan describes what RenderPlan would generate for the given blueprint, without running anything
+func (m *Golang) ExplainPlan(ctx context.Context, blueprint *dagger.Directory) (string, error) {
+	return bricks.ExplainPlan(ctx, blueprintSource(blueprint))
+}
 
-	outDirectory := dag.Directory()
-	for _, fileName := range fileNames {
-		data, err := blueprint.File(fileName).Contents(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get file contents for %s: %w", fileName, err)
-		}
+// Wiring returns how the bricks of the given blueprint are wired, as a JSON file,
+// to be given to the ValidateBlueprint function of the other modules
+func (m *Golang) Wiring(ctx context.Context, blueprint *dagger.Directory) (*dagger.File, error) {
+	return wiringFile(bricks.WiringJSON(ctx, blueprintSource(blueprint)))
+}
 
-		var brick mason.Brick
-		err = json.Unmarshal([]byte(data), &brick)
-		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal file %s: %w", fileName, err)
-		}
+// ValidateBlueprint validates the given blueprint before running anything:
+// the bricks themselves, and how they are wired together - including the bricks of the other modules
+func (m *Golang) ValidateBlueprint(
+	ctx context.Context,
+	blueprint *dagger.Directory,
+	// Wiring files of the other modules of the blueprint, returned by their Wiring function
+	// +optional
+	wirings []*dagger.File,
+	// Ordered phases of the pipeline, to check the phase order of the variables
+	// +optional
+	phases []string,
+) error {
+	return bricks.ValidateBlueprint(ctx, blueprintSource(blueprint), brickspec.Contents(wirings), phases)
+}
 
-		switch strings.ToLower(brick.Kind) {
-		case "gobinary":
-			var spec GoBinarySpec
-			err = json.Unmarshal(brick.Spec, &spec)
-			if err != nil {
-				return nil, fmt.Errorf("failed to unmarshal spec for file %s: %w", fileName, err)
-			}
-			outDirectory = addPlanToDirectory(spec.Plan, outDirectory, brick)
-		case "gotest":
-			var spec GoTestSpec
-			err = json.Unmarshal(brick.Spec, &spec)
-			if err != nil {
-				return nil, fmt.Errorf("failed to unmarshal spec for file %s: %w", fileName, err)
-			}
-			outDirectory = addPlanToDirectory(spec.Plan, outDirectory, brick)
-		case "golint":
-			var spec GoLintSpec
-			err = json.Unmarshal(brick.Spec, &spec)
-			if err != nil {
-				return nil, fmt.Errorf("failed to unmarshal spec for file %s: %w", fileName, err)
-			}
-			outDirectory = addPlanToDirectory(spec.Plan, outDirectory, brick)
-		default:
-			fmt.Println("Unknown kind:", brick.Kind, "for file", fileName)
-		}
-	}
+// Schemas returns the JSON Schema of each brick kind supported by this module,
+// to validate and autocomplete the blueprint files
+func (m *Golang) Schemas() (*dagger.Directory, error) {
+	return newDirectory(bricks.SchemaFiles())
+}
 
-	return outDirectory, nil
+func blueprintSource(blueprint *dagger.Directory) brickspec.BlueprintSource {
+	return brickspec.BlueprintSource{
+		Entries: func(ctx context.Context) ([]string, error) {
+			return blueprint.Entries(ctx)
+		},
+		Contents: func(ctx context.Context, name string) (string, error) {
+			return blueprint.File(name).Contents(ctx)
+		},
+	}
 }
 
-func addPlanToDirectory(
-	planFunc func(mason.Brick) map[string]string,
-	dir *dagger.Directory,
-	brick mason.Brick,
-) *dagger.Directory {
-	for filename, script := range planFunc(brick) {
-		dir = dir.WithNewFile(filename+".dagger", script)
+// newDirectory returns a directory of the given files, indexed by name - along with the error returned with them
+func newDirectory(files map[string]string, err error) (*dagger.Directory, error) {
+	directory := dag.Directory()
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		directory = directory.WithNewFile(name, files[name])
 	}
-	return dir
+	return directory, err
+}
+
+// wiringFile returns the wiring file of the given content - along with the error returned with it
+func wiringFile(wiring string, err error) (*dagger.File, error) {
+	return dag.File("wiring.json", wiring), err
 }
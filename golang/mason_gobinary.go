@@ -1,30 +1,72 @@
 package main
 
 import (
-	"fmt"
-	"strings"
+	"slices"
 
+	"github.com/vbehar/mason-modules/brickspec"
 	"github.com/vbehar/mason-sdk-go"
 )
 
 type GoBinarySpec struct {
-	OS        string              `json:"os"`
-	Arch      string              `json:"arch"`
-	Packages  []string            `json:"packages"`
-	BuildArgs []string            `json:"buildArgs"`
-	Sources   GoBinarySpecSources `json:"sources"`
-	Output    GoBinarySpecOutput  `json:"output"`
+	OS        string              `json:"os" description:"Go OS target. Defaults to the OS of the default platform."`
+	Arch      string              `json:"arch" description:"Go architecture target. Defaults to the architecture of the default platform."`
+	Packages  []string            `json:"packages" description:"Packages to build."`
+	BuildArgs []string            `json:"buildArgs" description:"\"go build\" extra arguments."`
+	Cover     bool                `json:"cover" description:"Build an instrumented binary, which writes coverage data to $GOCOVERDIR when run."`
+	PGO       GoBinarySpecPGO     `json:"pgo" description:"CPU profile used for profile-guided optimization."`
+	Size      GoBinarySpecSize    `json:"size" description:"Size analysis of the binary, and its size budget."`
+	Cgo       GoBinarySpecCgo     `json:"cgo" description:"Enable cgo, with a cross C toolchain for the target platform."`
+	Sources   GoBinarySpecSources `json:"sources" description:"Source code of the go module."`
+	Output    GoBinarySpecOutput  `json:"output" description:"Where to write the binary."`
 }
 
 type GoBinarySpecSources struct {
-	Path    string   `json:"path"`
-	Include []string `json:"include"`
-	Exclude []string `json:"exclude"`
+	Path    string   `json:"path" default:"." description:"Path of the source directory on the host."`
+	Include []string `json:"include" description:"Patterns of the files to include."`
+	Exclude []string `json:"exclude" description:"Patterns of the files to exclude."`
+}
+
+// GoBinarySpecPGO is the CPU profile used for profile-guided optimization
+type GoBinarySpecPGO struct {
+	DaggerFileName string `json:"daggerFileName" validate:"identifier" description:"Name of the dagger variable holding the CPU profile."`
+	HostFilePath   string `json:"hostFilePath" description:"Path of the CPU profile on the host."`
+}
+
+// GoBinarySpecCgo enables cgo, with a cross C toolchain for the target platform
+type GoBinarySpecCgo struct {
+	Enabled          bool   `json:"enabled" description:"Enable cgo."`
+	Libc             string `json:"libc" enum:"musl,gnu" description:"C library to link against on linux: musl for a fully static binary, or gnu for glibc."`
+	MacOSSDKHostPath string `json:"macosSdkHostPath" description:"Path on the host of the macOS SDK - such as MacOSX.sdk from Xcode - used as the sysroot on darwin."`
+}
+
+// GoBinarySpecSize configures the size analysis of the binary, and its size budget
+type GoBinarySpecSize struct {
+	MaxBytes           int                      `json:"maxBytes" description:"Maximum size of the binary file, in bytes."`
+	MaxIncreasePercent int                      `json:"maxIncreasePercent" description:"Maximum growth of the binary file compared to the baseline, in percent."`
+	Baseline           GoBinarySpecSizeBaseline `json:"baseline" description:"Previous JSON size report, to compare the binary against."`
+	Output             GoBinarySpecSizeOutput   `json:"output" description:"Where to write the size reports."`
+}
+
+type GoBinarySpecSizeBaseline struct {
+	DaggerFileName string `json:"daggerFileName" validate:"identifier" description:"Name of the dagger variable holding the baseline report."`
+	HostFilePath   string `json:"hostFilePath" description:"Path of the baseline report on the host."`
+}
+
+type GoBinarySpecSizeOutput struct {
+	JSONHostFilePath     string `json:"jsonHostFilePath" description:"Path on the host where to write the JSON size report."`
+	MarkdownHostFilePath string `json:"markdownHostFilePath" description:"Path on the host where to write the Markdown size report."`
 }
 
 type GoBinarySpecOutput struct {
-	DaggerFileName string `json:"daggerFileName"`
-	HostFilePath   string `json:"hostFilePath"`
+	DaggerFileName string `json:"daggerFileName" validate:"identifier" description:"Name of the dagger variable holding the binary, for use by other bricks."`
+	HostFilePath   string `json:"hostFilePath" description:"Path on the host where to write the binary."`
+}
+
+func (s GoBinarySpec) Validate() error {
+	if s.Cgo.MacOSSDKHostPath != "" && s.OS != "darwin" {
+		return brickspec.Errorf("cgo.macosSdkHostPath", "the macOS SDK is only used with the darwin OS, got %q", s.OS)
+	}
+	return nil
 }
 
 func (s GoBinarySpec) Plan(brick mason.Brick) map[string]string {
@@ -37,42 +79,104 @@ func (s GoBinarySpec) Plan(brick mason.Brick) map[string]string {
 	return plan
 }
 
-func (s GoBinarySpec) packageScript(brick mason.Brick) string {
-	src := "host | directory "
-	if s.Sources.Path != "" {
-		src += s.Sources.Path
-	} else {
-		src += "."
+func (s GoBinarySpec) Artifacts() brickspec.Artifacts {
+	artifacts := brickspec.Artifacts{
+		Inputs:   []string{s.Sources.Path},
+		Outputs:  []string{s.Output.HostFilePath},
+		Produces: []string{s.Output.DaggerFileName},
+		Consumes: []string{s.PGO.DaggerFileName},
 	}
-	if len(s.Sources.Include) > 0 {
-		src += ` --include "` + strings.Join(s.Sources.Include, `","`) + `"`
+	if s.PGO.DaggerFileName == "" {
+		artifacts.Inputs = append(artifacts.Inputs, s.PGO.HostFilePath)
 	}
-	if len(s.Sources.Exclude) > 0 {
-		src += ` --exclude "` + strings.Join(s.Sources.Exclude, `","`) + `"`
+	if s.Cgo.Enabled {
+		artifacts.Inputs = append(artifacts.Inputs, s.Cgo.MacOSSDKHostPath)
 	}
-
-	cmd := brick.ModuleRef + " --source $(" + src + ") | build-binary"
-	if s.OS != "" {
-		cmd += " --go-os " + s.OS
-	}
-	if s.Arch != "" {
-		cmd += " --go-arch " + s.Arch
-	}
-	if len(s.BuildArgs) > 0 || len(s.Packages) > 0 {
-		args := append(s.BuildArgs, s.Packages...)
-		cmd += ` --args "` + strings.Join(args, `","`) + `"`
+	if s.Size.enabled() {
+		artifacts.Outputs = append(artifacts.Outputs, s.Size.Output.JSONHostFilePath, s.Size.Output.MarkdownHostFilePath)
+		artifacts.Consumes = append(artifacts.Consumes, s.Size.Baseline.DaggerFileName)
+		if s.Size.Baseline.DaggerFileName == "" {
+			artifacts.Inputs = append(artifacts.Inputs, s.Size.Baseline.HostFilePath)
+		}
 	}
-	if s.Output.DaggerFileName != "" {
-		cmd += " --output-file-name " + s.Output.DaggerFileName
-		cmd = fmt.Sprintf("%s=$(%s)", s.Output.DaggerFileName, cmd)
-		if s.Output.HostFilePath != "" {
-			cmd += fmt.Sprintf("\n$%s | export %s", s.Output.DaggerFileName, s.Output.HostFilePath)
+	return artifacts
+}
+
+func (s GoBinarySpec) packageScript(brick mason.Brick) string {
+	buildCmd := brickspec.Command(brick.ModuleRef).
+		RefFlag("source", brickspec.Sub(brickspec.HostDirectory(s.Sources.Path, s.Sources.Include, s.Sources.Exclude))).
+		Pipe("build-binary").
+		Flag("go-os", s.OS).
+		Flag("go-arch", s.Arch).
+		ListFlag("args", slices.Concat(s.BuildArgs, s.Packages)).
+		BoolFlag("cover", s.Cover).
+		RefFlag("pgo-profile", fileRef(s.PGO.DaggerFileName, s.PGO.HostFilePath))
+	if s.Cgo.Enabled {
+		buildCmd.BoolFlag("cgo", true).Flag("cgo-libc", s.Cgo.Libc)
+		if s.Cgo.MacOSSDKHostPath != "" {
+			buildCmd.RefFlag("macos-sdk", brickspec.Sub(brickspec.HostDirectory(s.Cgo.MacOSSDKHostPath, nil, nil)))
 		}
-	} else {
+	}
+
+	var script brickspec.Script
+	binary := brickspec.Sub(buildCmd)
+	switch {
+	case s.Output.DaggerFileName != "":
+		script.Assign(s.Output.DaggerFileName, buildCmd.Flag("output-file-name", s.Output.DaggerFileName))
+		binary = brickspec.Var(s.Output.DaggerFileName)
 		if s.Output.HostFilePath != "" {
-			cmd += fmt.Sprintf(" | export %s", s.Output.HostFilePath)
+			script.Run(brickspec.FromRef(binary).Pipe("export", s.Output.HostFilePath))
 		}
+	case s.Output.HostFilePath != "":
+		script.Run(buildCmd.Pipe("export", s.Output.HostFilePath))
+	default:
+		script.Run(buildCmd)
+	}
+
+	if s.Size.enabled() {
+		script.Append(s.sizeScript(brick, binary))
+	}
+
+	return script.String()
+}
+
+func (s GoBinarySpec) sizeScript(brick mason.Brick, binary string) *brickspec.Script {
+	sizeVar := brick.Metadata.Name + "_binary_size"
+
+	var script brickspec.Script
+	script.Assign(sizeVar, brickspec.Command(brick.ModuleRef).
+		Pipe("binary-size").
+		Ref(binary).
+		IntFlag("max-bytes", s.Size.MaxBytes).
+		IntFlag("max-increase-percent", s.Size.MaxIncreasePercent).
+		RefFlag("baseline", fileRef(s.Size.Baseline.DaggerFileName, s.Size.Baseline.HostFilePath)))
+	if s.Size.Output.JSONHostFilePath != "" {
+		script.Run(brickspec.FromRef(brickspec.Var(sizeVar)).Pipe("json-file").Pipe("export", s.Size.Output.JSONHostFilePath))
 	}
+	if s.Size.Output.MarkdownHostFilePath != "" {
+		script.Run(brickspec.FromRef(brickspec.Var(sizeVar)).Pipe("markdown-file").Pipe("export", s.Size.Output.MarkdownHostFilePath))
+	}
+	script.Echo("")
+	script.Run(brickspec.FromRef(brickspec.Var(sizeVar)).Pipe("assert"))
+
+	return &script
+}
+
+// fileRef returns a reference to a file:
+// either a dagger variable produced by another brick, or a file on the host
+func fileRef(daggerFileName, hostFilePath string) string {
+	switch {
+	case daggerFileName != "":
+		return brickspec.Var(daggerFileName)
+	case hostFilePath != "":
+		return brickspec.Sub(brickspec.HostFile(hostFilePath))
+	default:
+		return ""
+	}
+}
 
-	return cmd
+func (s GoBinarySpecSize) enabled() bool {
+	return s.MaxBytes > 0 || s.MaxIncreasePercent > 0 ||
+		s.Baseline.DaggerFileName != "" || s.Baseline.HostFilePath != "" ||
+		s.Output.JSONHostFilePath != "" || s.Output.MarkdownHostFilePath != ""
 }
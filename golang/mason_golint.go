@@ -1,28 +1,26 @@
 package main
 
 import (
-	"fmt"
-	"strings"
-
+	"github.com/vbehar/mason-modules/brickspec"
 	"github.com/vbehar/mason-sdk-go"
 )
 
 type GoLintSpec struct {
-	LintArgs []string          `json:"lintArgs"`
-	Sources  GoLintSpecSources `json:"sources"`
-	Output   GoLintSpecOutput  `json:"output"`
+	LintArgs []string          `json:"lintArgs" description:"\"golangci-lint run\" extra arguments."`
+	Sources  GoLintSpecSources `json:"sources" description:"Source code of the go module."`
+	Output   GoLintSpecOutput  `json:"output" description:"Where to write the lint reports."`
 }
 
 type GoLintSpecSources struct {
-	Path                string   `json:"path"`
-	Include             []string `json:"include"`
-	Exclude             []string `json:"exclude"`
-	GolangCILintVersion string   `json:"golangCILintVersion"`
+	Path                string   `json:"path" default:"." description:"Path of the source directory on the host."`
+	Include             []string `json:"include" description:"Patterns of the files to include."`
+	Exclude             []string `json:"exclude" description:"Patterns of the files to exclude."`
+	GolangCILintVersion string   `json:"golangCILintVersion" description:"Version of the golangci-lint tool to use."`
 }
 
 type GoLintSpecOutput struct {
-	CodeClimateDaggerFileName string `json:"codeClimateDaggerFileName"`
-	CodeClimateHostFilePath   string `json:"codeClimateHostFilePath"`
+	CodeClimateDaggerFileName string `json:"codeClimateDaggerFileName" validate:"identifier" description:"Name of the dagger variable holding the Code Climate report, for use by other bricks."`
+	CodeClimateHostFilePath   string `json:"codeClimateHostFilePath" description:"Path on the host where to write the Code Climate report."`
 }
 
 func (s GoLintSpec) Plan(brick mason.Brick) map[string]string {
@@ -35,40 +33,35 @@ func (s GoLintSpec) Plan(brick mason.Brick) map[string]string {
 	return plan
 }
 
-func (s GoLintSpec) lintScript(brick mason.Brick) string {
-	src := "host | directory "
-	if s.Sources.Path != "" {
-		src += s.Sources.Path
-	} else {
-		src += "."
-	}
-	if len(s.Sources.Include) > 0 {
-		src += ` --include "` + strings.Join(s.Sources.Include, `","`) + `"`
-	}
-	if len(s.Sources.Exclude) > 0 {
-		src += ` --exclude "` + strings.Join(s.Sources.Exclude, `","`) + `"`
+func (s GoLintSpec) Artifacts() brickspec.Artifacts {
+	return brickspec.Artifacts{
+		Inputs:   []string{s.Sources.Path},
+		Outputs:  []string{s.Output.CodeClimateHostFilePath},
+		Produces: []string{s.Output.CodeClimateDaggerFileName},
 	}
+}
 
-	baseCmd := brick.ModuleRef + " --source $(" + src + ") | lint"
-	if s.Sources.GolangCILintVersion != "" {
-		baseCmd += " --golangcilint-version " + s.Sources.GolangCILintVersion
-	}
-	if len(s.LintArgs) > 0 {
-		baseCmd += " " + strings.Join(s.LintArgs, " ")
+func (s GoLintSpec) lintScript(brick mason.Brick) string {
+	baseCmd := func() *brickspec.Cmd {
+		return brickspec.Command(brick.ModuleRef).
+			RefFlag("source", brickspec.Sub(brickspec.HostDirectory(s.Sources.Path, s.Sources.Include, s.Sources.Exclude))).
+			Pipe("lint").
+			Flag("golangcilint-version", s.Sources.GolangCILintVersion).
+			ListFlag("args", s.LintArgs)
 	}
 
-	var cmd string
-	if s.Output.CodeClimateDaggerFileName != "" {
-		cmd += fmt.Sprintf("%s=$(%s | code-climate-file)", s.Output.CodeClimateDaggerFileName, baseCmd)
-		if s.Output.CodeClimateHostFilePath != "" {
-			cmd += fmt.Sprintf("\n$%s | export %s", s.Output.CodeClimateDaggerFileName, s.Output.CodeClimateHostFilePath)
-		}
-	} else {
+	var script brickspec.Script
+	switch {
+	case s.Output.CodeClimateDaggerFileName != "":
+		script.Assign(s.Output.CodeClimateDaggerFileName, baseCmd().Pipe("code-climate-file"))
 		if s.Output.CodeClimateHostFilePath != "" {
-			cmd += fmt.Sprintf("%s | code-climate-file | export %s", baseCmd, s.Output.CodeClimateHostFilePath)
+			script.Run(brickspec.FromRef(brickspec.Var(s.Output.CodeClimateDaggerFileName)).Pipe("export", s.Output.CodeClimateHostFilePath))
 		}
+	case s.Output.CodeClimateHostFilePath != "":
+		script.Run(baseCmd().Pipe("code-climate-file").Pipe("export", s.Output.CodeClimateHostFilePath))
 	}
-	cmd += "\n.echo\n" + baseCmd + " | assert"
+	script.Echo("")
+	script.Run(baseCmd().Pipe("assert"))
 
-	return cmd
+	return script.String()
 }
@@ -1,28 +1,28 @@
 package main
 
 import (
-	"fmt"
-	"strings"
+	"slices"
 
+	"github.com/vbehar/mason-modules/brickspec"
 	"github.com/vbehar/mason-sdk-go"
 )
 
 type GoTestSpec struct {
-	Packages []string          `json:"packages"`
-	TestArgs []string          `json:"testArgs"`
-	Sources  GoTestSpecSources `json:"sources"`
-	Output   GoTestSpecOutput  `json:"output"`
+	Packages []string          `json:"packages" description:"Packages to test."`
+	TestArgs []string          `json:"testArgs" description:"\"go test\" extra arguments."`
+	Sources  GoTestSpecSources `json:"sources" description:"Source code of the go module."`
+	Output   GoTestSpecOutput  `json:"output" description:"Where to write the test reports."`
 }
 
 type GoTestSpecSources struct {
-	Path    string   `json:"path"`
-	Include []string `json:"include"`
-	Exclude []string `json:"exclude"`
+	Path    string   `json:"path" default:"." description:"Path of the source directory on the host."`
+	Include []string `json:"include" description:"Patterns of the files to include."`
+	Exclude []string `json:"exclude" description:"Patterns of the files to exclude."`
 }
 
 type GoTestSpecOutput struct {
-	JUnitDaggerFileName string `json:"junitDaggerFileName"`
-	JUnitHostFilePath   string `json:"junitHostFilePath"`
+	JUnitDaggerFileName string `json:"junitDaggerFileName" validate:"identifier" description:"Name of the dagger variable holding the JUnit report, for use by other bricks."`
+	JUnitHostFilePath   string `json:"junitHostFilePath" description:"Path on the host where to write the JUnit report."`
 }
 
 func (s GoTestSpec) Plan(brick mason.Brick) map[string]string {
@@ -35,40 +35,34 @@ func (s GoTestSpec) Plan(brick mason.Brick) map[string]string {
 	return plan
 }
 
-func (s GoTestSpec) testScript(brick mason.Brick) string {
-	src := "host | directory "
-	if s.Sources.Path != "" {
-		src += s.Sources.Path
-	} else {
-		src += "."
-	}
-	if len(s.Sources.Include) > 0 {
-		src += ` --include "` + strings.Join(s.Sources.Include, `","`) + `"`
-	}
-	if len(s.Sources.Exclude) > 0 {
-		src += ` --exclude "` + strings.Join(s.Sources.Exclude, `","`) + `"`
+func (s GoTestSpec) Artifacts() brickspec.Artifacts {
+	return brickspec.Artifacts{
+		Inputs:   []string{s.Sources.Path},
+		Outputs:  []string{s.Output.JUnitHostFilePath},
+		Produces: []string{s.Output.JUnitDaggerFileName},
 	}
+}
 
-	baseCmd := brick.ModuleRef + " --source $(" + src + ") | test"
-	if len(s.TestArgs) > 0 {
-		baseCmd += " " + strings.Join(s.TestArgs, " ")
-	}
-	if len(s.Packages) > 0 {
-		baseCmd += " " + strings.Join(s.Packages, " ")
+func (s GoTestSpec) testScript(brick mason.Brick) string {
+	baseCmd := func() *brickspec.Cmd {
+		return brickspec.Command(brick.ModuleRef).
+			RefFlag("source", brickspec.Sub(brickspec.HostDirectory(s.Sources.Path, s.Sources.Include, s.Sources.Exclude))).
+			Pipe("test").
+			ListFlag("args", slices.Concat(s.TestArgs, s.Packages))
 	}
 
-	var cmd string
-	if s.Output.JUnitDaggerFileName != "" {
-		cmd += fmt.Sprintf("%s=$(%s | junit-file)", s.Output.JUnitDaggerFileName, baseCmd)
-		if s.Output.JUnitHostFilePath != "" {
-			cmd += fmt.Sprintf("\n$%s | export %s", s.Output.JUnitDaggerFileName, s.Output.JUnitHostFilePath)
-		}
-	} else {
+	var script brickspec.Script
+	switch {
+	case s.Output.JUnitDaggerFileName != "":
+		script.Assign(s.Output.JUnitDaggerFileName, baseCmd().Pipe("junit-file"))
 		if s.Output.JUnitHostFilePath != "" {
-			cmd += fmt.Sprintf("%s | junit-file | export %s", baseCmd, s.Output.JUnitHostFilePath)
+			script.Run(brickspec.FromRef(brickspec.Var(s.Output.JUnitDaggerFileName)).Pipe("export", s.Output.JUnitHostFilePath))
 		}
+	case s.Output.JUnitHostFilePath != "":
+		script.Run(baseCmd().Pipe("junit-file").Pipe("export", s.Output.JUnitHostFilePath))
 	}
-	cmd += "\n.echo\n" + baseCmd + " | assert"
+	script.Echo("")
+	script.Run(baseCmd().Pipe("assert"))
 
-	return cmd
+	return script.String()
 }
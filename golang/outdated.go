@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"dagger/golang/internal/dagger"
+)
+
+var (
+	majorVersionSuffixRegexp = regexp.MustCompile(`^(.*)/v([0-9]+)$`)
+	gopkgInPathRegexp        = regexp.MustCompile(`^(gopkg\.in/.*)\.v([0-9]+)$`)
+)
+
+// Outdated lists the direct and indirect dependencies which have newer versions available,
+// including new major versions, deprecated modules and retracted versions.
+func (g *Golang) Outdated(
+	ctx context.Context,
+	// directory used as a local GOPROXY (file:// protocol), instead of the default proxy
+	// +optional
+	goproxy *dagger.Directory,
+	// +optional
+	baseContainer *dagger.Container,
+) (*Outdated, error) {
+	ctr := g.Container(g.withGoProxy(baseContainer, goproxy)).
+		WithDirectory("/src", g.Source).
+		WithWorkdir(filepath.Join("/src", g.Module))
+
+	output, err := ctr.WithExec([]string{"go", "list", "-m", "-u", "-json", "all"}).Stdout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	modules, err := parseGoListModules(output)
+	if err != nil {
+		return nil, err
+	}
+
+	majorQueries := majorUpdateQueries(modules)
+	var majorModules []goListModule
+	if len(majorQueries) > 0 {
+		output, err = ctr.WithExec(append([]string{"go", "list", "-m", "-json", "-e"}, majorQueries...)).Stdout(ctx)
+		if err != nil {
+			return nil, err
+		}
+		majorModules, err = parseGoListModules(output)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Outdated{
+		Modules: outdatedModules(modules, majorModules),
+	}, nil
+}
+
+type Outdated struct {
+	Modules []OutdatedModule
+}
+
+type OutdatedModule struct {
+	Path        string   `json:"path"`
+	Version     string   `json:"version"`
+	Indirect    bool     `json:"indirect"`
+	Update      string   `json:"update,omitempty"`
+	MajorUpdate string   `json:"majorUpdate,omitempty"`
+	Deprecated  string   `json:"deprecated,omitempty"`
+	Retracted   []string `json:"retracted,omitempty"`
+}
+
+func (o *Outdated) JSONFile() (*dagger.File, error) {
+	data, err := json.MarshalIndent(o.Modules, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return dag.File("outdated.json", string(data)), nil
+}
+
+func (o *Outdated) Markdown() string {
+	if len(o.Modules) == 0 {
+		return "All dependencies are up to date.\n"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("| Module | Version | Update | Major update | Notes |\n|---|---|---|---|---|\n")
+	for _, mod := range o.Modules {
+		var notes []string
+		if mod.Indirect {
+			notes = append(notes, "indirect")
+		}
+		if mod.Deprecated != "" {
+			notes = append(notes, "⚠️ deprecated: "+mod.Deprecated)
+		}
+		if len(mod.Retracted) > 0 {
+			notes = append(notes, "⚠️ retracted: "+strings.Join(mod.Retracted, ", "))
+		}
+		fmt.Fprintf(&sb, "| `%s` | %s | %s | %s | %s |\n",
+			mod.Path, mod.Version, mod.Update, mod.MajorUpdate, strings.Join(notes, "<br>"))
+	}
+	return sb.String()
+}
+
+func (o *Outdated) MarkdownFile() *dagger.File {
+	return dag.File("outdated.md", o.Markdown())
+}
+
+// UpdateDependencies updates the dependencies of the go module,
+// and returns a directory with the updated go.mod and go.sum files
+// - once the tests have passed with the updated dependencies.
+func (g *Golang) UpdateDependencies(
+	ctx context.Context,
+	// only update to newer patch releases ("go get -u=patch")
+	// +optional
+	patchOnly bool,
+	// the packages or modules to update
+	// +optional
+	// +default=["./..."]
+	modules []string,
+	// "go test" extra arguments, used to validate the updated dependencies
+	// +optional
+	// +default=["./..."]
+	testArgs []string,
+	// directory used as a local GOPROXY (file:// protocol), instead of the default proxy
+	// +optional
+	goproxy *dagger.Directory,
+	// +optional
+	baseContainer *dagger.Container,
+	// The version of the gotestsum tool to use.
+	// +optional
+	// +default="1.12.1"
+	gotestsumVersion string,
+	// The version of the tparse tool to use.
+	// +optional
+	// +default="0.17.0"
+	tparseVersion string,
+) (*dagger.Directory, error) {
+	baseContainer = g.withGoProxy(baseContainer, goproxy)
+
+	getCmd := []string{"go", "get", "-u"}
+	if patchOnly {
+		getCmd = []string{"go", "get", "-u=patch"}
+	}
+	source := g.Container(baseContainer).
+		WithDirectory("/src", g.Source).
+		WithWorkdir(filepath.Join("/src", g.Module)).
+		WithExec(append(getCmd, modules...)).
+		WithExec([]string{"go", "mod", "tidy"}).
+		Directory("/src")
+
+	updated := New(source, g.Module)
+	testRun, err := updated.Test(ctx, testArgs, baseContainer, gotestsumVersion, tparseVersion)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := testRun.Assert(ctx); err != nil {
+		return nil, fmt.Errorf("tests failed with the updated dependencies: %w", err)
+	}
+
+	moduleDir := source.Directory(g.Module)
+	return dag.Directory().
+		WithFile("go.mod", moduleDir.File("go.mod")).
+		WithFile("go.sum", moduleDir.File("go.sum")), nil
+}
+
+// withGoProxy configures the base container to use the given directory as GOPROXY
+func (g *Golang) withGoProxy(baseContainer *dagger.Container, goproxy *dagger.Directory) *dagger.Container {
+	if goproxy == nil {
+		return baseContainer
+	}
+	if baseContainer == nil {
+		baseContainer = g.BaseBuildContainer()
+	}
+	return baseContainer.
+		WithMountedDirectory("/goproxy", goproxy).
+		WithEnvVariable("GOPROXY", "file:///goproxy").
+		WithEnvVariable("GOSUMDB", "off")
+}
+
+// goListModule is the JSON representation of a module, as returned by "go list -m -json"
+type goListModule struct {
+	Path     string
+	Version  string
+	Main     bool
+	Indirect bool
+	Update   *struct {
+		Version string
+	}
+	Deprecated string
+	Retracted  []string
+	Error      *struct {
+		Err string
+	}
+}
+
+// parseGoListModules parses the stream of JSON objects written by "go list -m -json"
+func parseGoListModules(output string) ([]goListModule, error) {
+	var modules []goListModule
+	decoder := json.NewDecoder(strings.NewReader(output))
+	for {
+		var mod goListModule
+		err := decoder.Decode(&mod)
+		if errors.Is(err, io.EOF) {
+			return modules, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse go list output: %w", err)
+		}
+		modules = append(modules, mod)
+	}
+}
+
+// majorUpdateQueries returns the "path@latest" queries
+// for the next major version of each direct dependency
+func majorUpdateQueries(modules []goListModule) []string {
+	var queries []string
+	for _, mod := range modules {
+		if mod.Main || mod.Indirect {
+			continue
+		}
+		if path := nextMajorPath(mod.Path, mod.Version); path != "" {
+			queries = append(queries, path+"@latest")
+		}
+	}
+	return queries
+}
+
+// nextMajorPath returns the module path of the next major version,
+// or an empty string for v0 modules
+func nextMajorPath(path, version string) string {
+	if m := gopkgInPathRegexp.FindStringSubmatch(path); m != nil {
+		major, _ := strconv.Atoi(m[2]) //nolint:errcheck // guaranteed by the regexp
+		return fmt.Sprintf("%s.v%d", m[1], major+1)
+	}
+
+	major, err := strconv.Atoi(strings.SplitN(strings.TrimPrefix(version, "v"), ".", 2)[0])
+	if err != nil || major < 1 {
+		return ""
+	}
+	if m := majorVersionSuffixRegexp.FindStringSubmatch(path); m != nil {
+		path = m[1]
+	}
+	return fmt.Sprintf("%s/v%d", path, major+1)
+}
+
+func outdatedModules(modules, majorModules []goListModule) []OutdatedModule {
+	majorUpdates := make(map[string]string, len(majorModules))
+	for _, mod := range majorModules {
+		if mod.Error == nil && mod.Version != "" {
+			majorUpdates[mod.Path] = mod.Path + "@" + mod.Version
+		}
+	}
+
+	var outdated []OutdatedModule
+	for _, mod := range modules {
+		if mod.Main {
+			continue
+		}
+		entry := OutdatedModule{
+			Path:       mod.Path,
+			Version:    mod.Version,
+			Indirect:   mod.Indirect,
+			Deprecated: mod.Deprecated,
+			Retracted:  mod.Retracted,
+		}
+		if mod.Update != nil {
+			entry.Update = mod.Update.Version
+		}
+		if !mod.Indirect {
+			entry.MajorUpdate = majorUpdates[nextMajorPath(mod.Path, mod.Version)]
+		}
+		if entry.Update == "" && entry.MajorUpdate == "" && entry.Deprecated == "" && len(entry.Retracted) == 0 {
+			continue
+		}
+		outdated = append(outdated, entry)
+	}
+	return outdated
+}
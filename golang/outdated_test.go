@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// goProxyModule is a module version served by the local GOPROXY stand-in
+type goProxyModule struct {
+	path    string
+	version string
+	goMod   string
+}
+
+// writeGoProxy writes a GOPROXY directory, to be used with the file:// protocol
+func writeGoProxy(t *testing.T, dir string, modules []goProxyModule) {
+	t.Helper()
+	versions := make(map[string]string)
+	for _, mod := range modules {
+		versionsDir := filepath.Join(dir, mod.path, "@v")
+		if err := os.MkdirAll(versionsDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		files := map[string]string{
+			mod.version + ".info": `{"Version":"` + mod.version + `","Time":"2025-01-01T00:00:00Z"}`,
+			mod.version + ".mod":  mod.goMod,
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(versionsDir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		versions[mod.path] += mod.version + "\n"
+	}
+	for path, list := range versions {
+		if err := os.WriteFile(filepath.Join(dir, path, "@v", "list"), []byte(list), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func goList(t *testing.T, dir, proxyDir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("go", append([]string{"list", "-m", "-json"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GOPROXY=file://"+filepath.ToSlash(proxyDir),
+		"GOSUMDB=off",
+		"GOFLAGS=-mod=mod",
+		"GOMODCACHE="+t.TempDir(),
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("go list %v failed: %v", args, err)
+	}
+	return string(output)
+}
+
+func TestOutdated(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not available")
+	}
+
+	proxyDir := t.TempDir()
+	writeGoProxy(t, proxyDir, []goProxyModule{
+		{"example.com/direct", "v1.0.0", "module example.com/direct\n"},
+		{"example.com/direct", "v1.1.0", "module example.com/direct\n"},
+		{"example.com/direct/v2", "v2.0.0", "module example.com/direct/v2\n"},
+		{"example.com/indirect", "v0.1.0", "module example.com/indirect\n"},
+		{"example.com/indirect", "v0.2.0", "module example.com/indirect\n"},
+		{"example.com/deprecated", "v1.0.0", "// Deprecated: use example.com/direct instead.\nmodule example.com/deprecated\n"},
+		{"example.com/retracted", "v1.0.0", "module example.com/retracted\n"},
+		{"example.com/retracted", "v1.0.1", "module example.com/retracted\n\nretract v1.0.0 // broken\n"},
+		{"example.com/uptodate", "v1.0.0", "module example.com/uptodate\n"},
+	})
+
+	moduleDir := t.TempDir()
+	goMod := `module example.com/main
+
+go 1.21
+
+require (
+	example.com/deprecated v1.0.0
+	example.com/direct v1.0.0
+	example.com/retracted v1.0.0
+	example.com/uptodate v1.0.0
+	example.com/indirect v0.1.0 // indirect
+)
+`
+	if err := os.WriteFile(filepath.Join(moduleDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	modules, err := parseGoListModules(goList(t, moduleDir, proxyDir, "-u", "all"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	majorQueries := majorUpdateQueries(modules)
+	majorModules, err := parseGoListModules(goList(t, moduleDir, proxyDir, append([]string{"-e"}, majorQueries...)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outdated := make(map[string]OutdatedModule)
+	for _, mod := range outdatedModules(modules, majorModules) {
+		outdated[mod.Path] = mod
+	}
+
+	if _, ok := outdated["example.com/uptodate"]; ok {
+		t.Errorf("example.com/uptodate should not be reported as outdated")
+	}
+	if mod := outdated["example.com/direct"]; mod.Update != "v1.1.0" || mod.MajorUpdate != "example.com/direct/v2@v2.0.0" {
+		t.Errorf("unexpected example.com/direct: %+v", mod)
+	}
+	if mod := outdated["example.com/indirect"]; !mod.Indirect || mod.Update != "v0.2.0" {
+		t.Errorf("unexpected example.com/indirect: %+v", mod)
+	}
+	if mod := outdated["example.com/deprecated"]; mod.Deprecated != "use example.com/direct instead." {
+		t.Errorf("unexpected example.com/deprecated: %+v", mod)
+	}
+	if mod := outdated["example.com/retracted"]; len(mod.Retracted) != 1 || mod.Retracted[0] != "broken" {
+		t.Errorf("unexpected example.com/retracted: %+v", mod)
+	}
+}
+
+func TestNextMajorPath(t *testing.T) {
+	for _, tc := range []struct {
+		path     string
+		version  string
+		expected string
+	}{
+		{"github.com/foo/bar", "v1.2.3", "github.com/foo/bar/v2"},
+		{"github.com/foo/bar/v2", "v2.0.0", "github.com/foo/bar/v3"},
+		{"github.com/foo/bar", "v0.4.0", ""},
+		{"github.com/foo/bar", "v3.1.0+incompatible", "github.com/foo/bar/v4"},
+		{"gopkg.in/yaml.v2", "v2.4.0", "gopkg.in/yaml.v3"},
+	} {
+		if actual := nextMajorPath(tc.path, tc.version); actual != tc.expected {
+			t.Errorf("nextMajorPath(%q, %q) = %q, expected %q", tc.path, tc.version, actual, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"dagger/golang/internal/dagger"
+)
+
+const (
+	pgoProfileFilePath   = "/pgo/default.pgo"
+	cpuProfilesDirPath   = "/output/cpu-profiles"
+	mergedCPUProfilePath = "/output/default.pgo"
+)
+
+// CollectProfile runs the tests - or the benchmarks - of the given packages with CPU profiling enabled,
+// and returns a merged profile that can be used for profile-guided optimization.
+// See https://go.dev/doc/pgo
+func (g *Golang) CollectProfile(
+	ctx context.Context,
+	// Packages to profile
+	// +optional
+	// +default=["./..."]
+	packages []string,
+	// Benchmarks to run, as a regular expression ("go test -bench")
+	// If empty, the tests are run instead
+	// +optional
+	bench string,
+	// "go test" extra arguments
+	// +optional
+	args []string,
+	// +optional
+	baseContainer *dagger.Container,
+) (*dagger.File, error) {
+	ctr := g.Container(baseContainer).
+		WithDirectory("/src", g.Source).
+		WithWorkdir(filepath.Join("/src", g.Module)).
+		WithExec([]string{"mkdir", "-p", cpuProfilesDirPath})
+
+	// "go test -cpuprofile" only supports a single package at a time,
+	// and packages without tests don't produce any profile
+	output, err := ctr.WithExec(append([]string{
+		"go", "list", "-f", "{{if or .TestGoFiles .XTestGoFiles}}{{.ImportPath}}{{end}}",
+	}, packages...)).Stdout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	testedPackages := strings.Fields(output)
+	if len(testedPackages) == 0 {
+		return nil, fmt.Errorf("no packages with tests found in %v", packages)
+	}
+
+	var profiles []string
+	for i, pkg := range testedPackages {
+		profile := fmt.Sprintf("%s/%d.pprof", cpuProfilesDirPath, i)
+		cmd := []string{"go", "test", "-cpuprofile=" + profile}
+		if bench != "" {
+			cmd = append(cmd, "-run=^$", "-bench="+bench)
+		}
+		cmd = append(cmd, args...)
+		ctr = ctr.WithExec(append(cmd, pkg))
+		profiles = append(profiles, profile)
+	}
+
+	return ctr.
+		WithExec(append([]string{"go", "tool", "pprof", "-proto"}, profiles...), dagger.ContainerWithExecOpts{
+			RedirectStdout: mergedCPUProfilePath,
+		}).
+		File(mergedCPUProfilePath).
+		WithName("default.pgo"), nil
+}
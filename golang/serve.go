@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"dagger/golang/internal/dagger"
+)
+
+const (
+	serveBinaryPath = "/usr/local/bin/app"
+	dlvBinaryPath   = "/usr/local/bin/dlv"
+)
+
+// Serve builds the main package and runs it as a service.
+// Use it with "dagger up" for a local dev loop:
+// the binary is built when the service is created, so run "dagger up" again to rebuild it after a change.
+func (g *Golang) Serve(
+	ctx context.Context,
+	// the main package to build
+	// +optional
+	// +default="."
+	pkg string,
+	// ports exposed by the service
+	// +optional
+	// +default=[8080]
+	ports []int,
+	// environment variables, in the KEY=VALUE format
+	// +optional
+	env []string,
+	// arguments passed to the binary
+	// +optional
+	args []string,
+	// "go build" extra arguments
+	// +optional
+	buildArgs []string,
+	// +optional
+	baseContainer *dagger.Container,
+) (*dagger.Service, error) {
+	binary := g.buildBinary(ctx, binaryBuild{
+		args:           append(buildArgs, pkg),
+		outputFileName: "app",
+		baseContainer:  baseContainer,
+	})
+
+	ctr, err := g.serviceContainer(binary, ports, env)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctr.AsService(dagger.ContainerAsServiceOpts{
+		Args: append([]string{serveBinaryPath}, args...),
+	}), nil
+}
+
+// Debug builds the main package without optimizations,
+// and runs it under delve in headless mode, as a service.
+// Editors can attach to the debugger port (DAP or JSON-RPC) exposed with "dagger up".
+func (g *Golang) Debug(
+	ctx context.Context,
+	// the main package to build
+	// +optional
+	// +default="."
+	pkg string,
+	// ports exposed by the service, in addition to the debugger port
+	// +optional
+	// +default=[8080]
+	ports []int,
+	// port of the delve debugger
+	// +optional
+	// +default=2345
+	debugPort int,
+	// environment variables, in the KEY=VALUE format
+	// +optional
+	env []string,
+	// arguments passed to the binary
+	// +optional
+	args []string,
+	// "go build" extra arguments
+	// +optional
+	buildArgs []string,
+	// The version of the delve debugger to use.
+	// See https://github.com/go-delve/delve/releases
+	// +optional
+	// +default="1.25.0"
+	delveVersion string,
+	// +optional
+	baseContainer *dagger.Container,
+) (*dagger.Service, error) {
+	buildArgs = append([]string{"-gcflags=all=-N -l"}, buildArgs...)
+	binary := g.buildBinary(ctx, binaryBuild{
+		args:           append(buildArgs, pkg),
+		outputFileName: "app",
+		baseContainer:  baseContainer,
+	})
+
+	ctr, err := g.serviceContainer(binary, append(ports, debugPort), env)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := []string{
+		dlvBinaryPath, "exec", serveBinaryPath,
+		"--headless",
+		fmt.Sprintf("--listen=:%d", debugPort),
+		"--api-version=2",
+		"--accept-multiclient",
+	}
+	if len(args) > 0 {
+		cmd = append(append(cmd, "--"), args...)
+	}
+
+	return ctr.
+		WithFile(dlvBinaryPath, g.dlvFile(baseContainer, delveVersion)).
+		AsService(dagger.ContainerAsServiceOpts{
+			Args: cmd,
+		}), nil
+}
+
+func (g *Golang) serviceContainer(binary *dagger.File, ports []int, env []string) (*dagger.Container, error) {
+	ctr := g.BaseRunContainer("").
+		WithFile(serveBinaryPath, binary)
+	for _, port := range ports {
+		ctr = ctr.WithExposedPort(port)
+	}
+	for _, kv := range env {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid environment variable %q: must be in the KEY=VALUE format", kv)
+		}
+		ctr = ctr.WithEnvVariable(key, value)
+	}
+	return ctr, nil
+}
+
+func (g *Golang) dlvFile(baseContainer *dagger.Container, delveVersion string) *dagger.File {
+	ctr := baseContainer
+	if ctr == nil {
+		ctr = g.BaseBuildContainer()
+	}
+	return ctr.
+		WithEnvVariable("CGO_ENABLED", "0").
+		WithEnvVariable("GOBIN", "/go/bin").
+		WithExec([]string{"go", "install", "github.com/go-delve/delve/cmd/dlv@v" + delveVersion}).
+		File("/go/bin/dlv")
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"dagger/mason-git-info/gitrepo"
+	"dagger/mason-git-info/internal/dagger"
+)
+
+// archiveFormats are the formats supported by Archive
+var archiveFormats = []string{"tar.gz", "zip"}
+
+// Archive returns the sources at the given ref as an archive, from git archive:
+// the files with the export-ignore attribute in .gitattributes are excluded,
+// and the modification times are the commit time, for reproducible archives.
+func (g *MasonGitInfo) Archive(
+	ctx context.Context,
+	// ref of the sources, such as a tag
+	// +optional
+	// +default="HEAD"
+	ref string,
+	// format of the archive: tar.gz or zip
+	// +optional
+	// +default="tar.gz"
+	format string,
+	// directory prefixed to the paths of the archive, such as myproject-1.0.0
+	// +optional
+	prefix string,
+) (*dagger.File, error) {
+	if !slices.Contains(archiveFormats, format) {
+		return nil, fmt.Errorf("unsupported archive format %q: must be one of %s", format, strings.Join(archiveFormats, ", "))
+	}
+	commit, err := g.resolveCommit(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"archive", "--format=" + format}
+	name := "source"
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		args = append(args, "--prefix="+prefix+"/")
+		name = path.Base(prefix)
+	}
+
+	// the archives of a commit - not of a tree - use the commit time as mtime
+	output := "/tmp/" + name + "." + format
+	if _, err := g.git(ctx, append(args, "--output="+output, commit)...); err != nil {
+		return nil, err
+	}
+	return g.Container.File(output), nil
+}
+
+// Checkout returns a clean checkout of the sources at the given ref - without the .git directory,
+// the untracked files and the local changes - such as to build a previous version.
+// Unlike Archive, the files with the export-ignore attribute are included.
+// The modification times are the commit time, for reproducible builds.
+func (g *MasonGitInfo) Checkout(
+	ctx context.Context,
+	// ref of the sources, such as a tag
+	// +optional
+	// +default="HEAD"
+	ref string,
+) (*dagger.Directory, error) {
+	commit, err := g.resolveCommit(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	commitTime, err := g.git(ctx, "log", "-1", "--format=%ct", commit)
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := strconv.Atoi(strings.TrimSpace(commitTime))
+	if err != nil {
+		return nil, fmt.Errorf("invalid commit time %q: %w", commitTime, err)
+	}
+
+	// use a temporary index, to keep the one of the repository untouched
+	checkout := g.Container.
+		WithEnvVariable("GIT_INDEX_FILE", "/tmp/checkout.index").
+		WithExec([]string{"mkdir", "-p", "/tmp/checkout"}).
+		WithExec([]string{"git", "read-tree", commit}).
+		WithExec([]string{"git", "checkout-index", "--all", "--prefix=/tmp/checkout/"})
+	return checkout.Directory("/tmp/checkout").WithTimestamps(timestamp), nil
+}
+
+// resolveCommit returns the SHA of the commit of the given ref - fetching it if missing and enabled
+func (g *MasonGitInfo) resolveCommit(ctx context.Context, ref string) (string, error) {
+	if ref == "" {
+		// such as the ${git.tag} of a blueprint, on an untagged commit
+		return "", fmt.Errorf("empty ref: the ref of the sources must be set, such as a tag or HEAD")
+	}
+	if err := gitrepo.EnsureCommit(ctx, g.git, ref, g.FetchMissingRefs); err != nil {
+		return "", err
+	}
+	return g.revParse(ctx, "--verify", ref+"^{commit}")
+}
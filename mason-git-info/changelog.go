@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"dagger/mason-git-info/internal/dagger"
+)
+
+// changelogSections are the titles of the changelog sections, in order, by conventional commit type
+var changelogSections = []struct {
+	Type  string
+	Title string
+}{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance Improvements"},
+	{"revert", "Reverts"},
+	{"refactor", "Code Refactoring"},
+	{"docs", "Documentation"},
+	{"test", "Tests"},
+	{"build", "Build System"},
+	{"ci", "Continuous Integration"},
+	{"style", "Styles"},
+	{"chore", "Chores"},
+	// the other types, and the commits which don't follow the conventional commits format
+	{"", "Other Changes"},
+}
+
+var pullRequestRe = regexp.MustCompile(`(?:^|[\s(])#(\d+)\b`)
+
+// Changelog returns the Markdown changelog of the commits between two refs:
+// the commits are grouped by conventional commit type and scope, with the breaking changes first,
+// the pull request numbers are linked and the contributors are listed.
+func (g *MasonGitInfo) Changelog(
+	ctx context.Context,
+	// start of the range, excluded - default to the latest semver tag before the end of the range
+	// +optional
+	from string,
+	// end of the range, included
+	// +optional
+	// +default="HEAD"
+	to string,
+	// prefix of the tags, such as "api/" for the tags of a monorepo module, to find the default start of the range
+	// +optional
+	tagPrefix string,
+	// title of the changelog - default to the end of the range, or "Unreleased" for HEAD
+	// +optional
+	title string,
+	// URL of the repository, for the links to the commits and pull requests - default to the origin remote
+	// +optional
+	repoURL string,
+) (*dagger.File, error) {
+	if from == "" {
+		tag, _, err := g.latestVersionTag(ctx, tagPrefix, "--merged", to, "--no-contains", to)
+		if err != nil {
+			return nil, err
+		}
+		from = tag
+	}
+	revisionRange := to
+	if from != "" {
+		revisionRange = from + ".." + to
+	}
+	commits, err := g.commits(ctx, revisionRange, "--no-merges")
+	if err != nil {
+		return nil, err
+	}
+
+	if title == "" {
+		title = to
+		if to == "HEAD" {
+			title = "Unreleased"
+		}
+	}
+	date, err := g.Container.WithExec([]string{
+		"git", "log", "-1", "--format=%cs", to, "--",
+	}).Stdout(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if repoURL == "" {
+		// the links are optional: ignore the repositories without remote
+		repoURL, _ = g.RepoURL(ctx)
+	}
+
+	changelog := renderChangelog(fmt.Sprintf("%s (%s)", title, strings.TrimSpace(date)), commits, webURL(repoURL))
+	return dag.File("CHANGELOG.md", changelog), nil
+}
+
+// renderChangelog returns the Markdown changelog of the given commits, newest first.
+// The links are only rendered if the web URL of the repository is set.
+func renderChangelog(title string, commits []commit, repoURL string) string {
+	type entry struct {
+		commit       commit
+		conventional conventionalCommit
+	}
+	var entries []entry
+	var contributors []string
+	for _, c := range commits {
+		conventional, _ := parseConventionalCommit(c.Message)
+		entries = append(entries, entry{commit: c, conventional: conventional})
+		if !slices.Contains(contributors, c.AuthorName) {
+			contributors = append(contributors, c.AuthorName)
+		}
+	}
+	// group by scope - the commits without scope first - keeping the commits order within a scope
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		return strings.Compare(a.conventional.Scope, b.conventional.Scope)
+	})
+
+	line := func(scope, description string, c commit) string {
+		if scope != "" {
+			scope = "**" + scope + ":** "
+		}
+		return fmt.Sprintf("- %s%s (%s)\n", scope, linkPullRequests(description, repoURL), commitLink(c.Hash, repoURL))
+	}
+
+	var md strings.Builder
+	fmt.Fprintf(&md, "## %s\n", title)
+	if len(entries) == 0 {
+		md.WriteString("\nNo changes.\n")
+		return md.String()
+	}
+
+	var breaking strings.Builder
+	for _, e := range entries {
+		if e.conventional.Breaking {
+			breaking.WriteString(line(e.conventional.Scope, e.conventional.breakingChange(), e.commit))
+		}
+	}
+	if breaking.Len() > 0 {
+		fmt.Fprintf(&md, "\n### ⚠ BREAKING CHANGES\n\n%s", breaking.String())
+	}
+
+	knownType := func(commitType string) bool {
+		for _, section := range changelogSections {
+			if section.Type != "" && section.Type == commitType {
+				return true
+			}
+		}
+		return false
+	}
+	for _, section := range changelogSections {
+		var lines strings.Builder
+		for _, e := range entries {
+			if e.conventional.Type == section.Type || (section.Type == "" && !knownType(e.conventional.Type)) {
+				lines.WriteString(line(e.conventional.Scope, e.conventional.Subject, e.commit))
+			}
+		}
+		if lines.Len() > 0 {
+			fmt.Fprintf(&md, "\n### %s\n\n%s", section.Title, lines.String())
+		}
+	}
+
+	slices.Sort(contributors)
+	md.WriteString("\n### Contributors\n\n")
+	for _, contributor := range contributors {
+		fmt.Fprintf(&md, "- %s\n", contributor)
+	}
+	return md.String()
+}
+
+// linkPullRequests replaces the pull request numbers, such as #123, by links to the pull requests
+func linkPullRequests(text, repoURL string) string {
+	if repoURL == "" {
+		return text
+	}
+	pullPath := "/pull/"
+	if strings.Contains(repoURL, "gitlab") {
+		pullPath = "/-/merge_requests/"
+	}
+	return pullRequestRe.ReplaceAllStringFunc(text, func(match string) string {
+		prefix, number, _ := strings.Cut(match, "#")
+		return fmt.Sprintf("%s[#%s](%s%s%s)", prefix, number, repoURL, pullPath, number)
+	})
+}
+
+func commitLink(hash, repoURL string) string {
+	shortHash := hash[:min(len(hash), 7)]
+	if repoURL == "" {
+		return shortHash
+	}
+	return fmt.Sprintf("[%s](%s/commit/%s)", shortHash, repoURL, hash)
+}
+
+// webURL returns the HTTPS URL of the repository with the given remote URL,
+// without credentials nor .git suffix - or an empty string if it can't be computed
+func webURL(remoteURL string) string {
+	remoteURL = strings.TrimSpace(remoteURL)
+	if remoteURL == "" {
+		return ""
+	}
+	if !strings.Contains(remoteURL, "://") {
+		// scp-like syntax: [user@]host:path
+		host, path, ok := strings.Cut(remoteURL, ":")
+		if !ok {
+			return ""
+		}
+		if _, h, ok := strings.Cut(host, "@"); ok {
+			host = h
+		}
+		remoteURL = "ssh://" + host + "/" + strings.TrimPrefix(path, "/")
+	}
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	return "https://" + u.Hostname() + strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git")
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"dagger/mason-git-info/internal/dagger"
+)
+
+// changeList is the list of the files changed since the merge-base of a base ref
+type changeList struct {
+	Base      string        `json:"base"`
+	MergeBase string        `json:"mergeBase"`
+	Files     []changedFile `json:"files"`
+	// the changes rolled up by package or directory, if requested
+	Rollup []changeRollup `json:"rollup,omitempty"`
+}
+
+// changedFile is a file changed since the merge-base
+type changedFile struct {
+	Path string `json:"path"`
+	// added, modified, deleted, renamed, copied or typechanged
+	Status string `json:"status"`
+	// the previous path of a renamed or copied file
+	OldPath   string `json:"oldPath,omitempty"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+// changeRollup are the changes of a package or directory
+type changeRollup struct {
+	Path      string   `json:"path"`
+	Files     []string `json:"files"`
+	Additions int      `json:"additions"`
+	Deletions int      `json:"deletions"`
+}
+
+var changeStatuses = map[byte]string{
+	'A': "added",
+	'M': "modified",
+	'D': "deleted",
+	'R': "renamed",
+	'C': "copied",
+	'T': "typechanged",
+}
+
+// ChangedFiles returns the files changed since the merge-base of HEAD and the base ref, as a JSON file,
+// with their status - renames are detected - and line stats.
+// The changes can be rolled up by package - the closest directory containing one of the marker files -
+// or by directory, up to a depth.
+func (g *MasonGitInfo) ChangedFiles(
+	ctx context.Context,
+	// base ref the changes are compared to
+	// +optional
+	// +default="origin/main"
+	base string,
+	// include the local changes: staged, unstaged and untracked
+	// +optional
+	// +default=true
+	localChanges bool,
+	// names of the files marking the root of a package, such as go.mod or package.json
+	// +optional
+	rollupMarkers []string,
+	// number of directory levels of the rollup, when there are no markers - 0 to disable it
+	// +optional
+	rollupDepth int,
+) (*dagger.File, error) {
+	changes, err := g.changeList(ctx, base, localChanges, rollupMarkers, rollupDepth)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return dag.File("changed-files.json", string(data)), nil
+}
+
+// changeList returns the files changed since the merge-base of HEAD and the base ref
+func (g *MasonGitInfo) changeList(ctx context.Context, base string, localChanges bool, rollupMarkers []string, rollupDepth int) (*changeList, error) {
+	mergeBase, err := g.mergeBase(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []string{mergeBase}
+	if !localChanges {
+		revisions = append(revisions, "HEAD")
+	}
+	nameStatus, err := g.Container.WithExec(slices.Concat([]string{
+		"git", "diff", "--find-renames", "--name-status", "-z",
+	}, revisions)).Stdout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	numstat, err := g.Container.WithExec(slices.Concat([]string{
+		"git", "diff", "--find-renames", "--numstat", "-z",
+	}, revisions)).Stdout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	files, err := parseChangedFiles(nameStatus, numstat)
+	if err != nil {
+		return nil, err
+	}
+
+	if localChanges {
+		numstat, err := g.untrackedDiff(ctx, []string{"--numstat", "-z"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		stats, err := parseNumstat(numstat)
+		if err != nil {
+			return nil, err
+		}
+		for file, stat := range stats {
+			files = append(files, changedFile{
+				Path:      file,
+				Status:    "added",
+				Additions: stat.additions,
+				Deletions: stat.deletions,
+				Binary:    stat.binary,
+			})
+		}
+		slices.SortFunc(files, func(a, b changedFile) int { return strings.Compare(a.Path, b.Path) })
+	}
+
+	changes := &changeList{
+		Base:      base,
+		MergeBase: mergeBase,
+		Files:     files,
+	}
+	switch {
+	case len(rollupMarkers) > 0:
+		packages, err := g.packageRoots(ctx, rollupMarkers)
+		if err != nil {
+			return nil, err
+		}
+		changes.Rollup = rollupChanges(files, func(file string) string {
+			return packageRoot(packages, file)
+		})
+	case rollupDepth > 0:
+		changes.Rollup = rollupChanges(files, func(file string) string {
+			return directoryRoot(file, rollupDepth)
+		})
+	}
+	return changes, nil
+}
+
+// packageRoots returns the directories containing one of the marker files
+func (g *MasonGitInfo) packageRoots(ctx context.Context, markers []string) ([]string, error) {
+	output, err := g.Container.WithExec([]string{
+		"git", "ls-files", "--cached", "--others", "--exclude-standard", "-z",
+	}).Stdout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var roots []string
+	for _, file := range splitNul(output) {
+		if slices.Contains(markers, path.Base(file)) {
+			roots = append(roots, path.Dir(file))
+		}
+	}
+	slices.Sort(roots)
+	return slices.Compact(roots), nil
+}
+
+// parseChangedFiles merges the output of git diff --name-status -z and --numstat -z
+func parseChangedFiles(nameStatus, numstat string) ([]changedFile, error) {
+	var files []changedFile
+	fields := splitNul(nameStatus)
+	for i := 0; i < len(fields); i++ {
+		code := fields[i]
+		status, ok := changeStatuses[code[0]]
+		if !ok {
+			return nil, fmt.Errorf("unexpected git diff status %q", code)
+		}
+		file := changedFile{Status: status}
+		switch code[0] {
+		case 'R', 'C':
+			if i+2 >= len(fields) {
+				return nil, fmt.Errorf("missing paths for git diff status %q", code)
+			}
+			file.OldPath, file.Path = fields[i+1], fields[i+2]
+			i += 2
+		default:
+			if i+1 >= len(fields) {
+				return nil, fmt.Errorf("missing path for git diff status %q", code)
+			}
+			file.Path = fields[i+1]
+			i++
+		}
+		files = append(files, file)
+	}
+
+	stats, err := parseNumstat(numstat)
+	if err != nil {
+		return nil, err
+	}
+	for i, file := range files {
+		if stat, ok := stats[file.Path]; ok {
+			files[i].Additions, files[i].Deletions, files[i].Binary = stat.additions, stat.deletions, stat.binary
+		}
+	}
+	return files, nil
+}
+
+type lineStats struct {
+	additions int
+	deletions int
+	binary    bool
+}
+
+// parseNumstat parses the output of git diff --numstat -z, indexed by the new path of the files
+func parseNumstat(numstat string) (map[string]lineStats, error) {
+	stats := make(map[string]lineStats)
+	fields := strings.Split(numstat, "\x00")
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == "" {
+			continue
+		}
+		additions, rest, _ := strings.Cut(fields[i], "\t")
+		deletions, file, ok := strings.Cut(rest, "\t")
+		if !ok {
+			return nil, fmt.Errorf("unexpected git diff numstat %q", fields[i])
+		}
+		if file == "" {
+			// renamed or copied: the old path, then the new path
+			if i+2 >= len(fields) || fields[i+2] == "" {
+				return nil, fmt.Errorf("missing paths for git diff numstat %q", fields[i])
+			}
+			file = fields[i+2]
+			i += 2
+		}
+		if additions == "-" && deletions == "-" {
+			stats[file] = lineStats{binary: true}
+			continue
+		}
+		var stat lineStats
+		var err error
+		if stat.additions, err = strconv.Atoi(additions); err != nil {
+			return nil, fmt.Errorf("unexpected git diff numstat %q: %w", fields[i], err)
+		}
+		if stat.deletions, err = strconv.Atoi(deletions); err != nil {
+			return nil, fmt.Errorf("unexpected git diff numstat %q: %w", fields[i], err)
+		}
+		stats[file] = stat
+	}
+	return stats, nil
+}
+
+// rollupChanges groups the changed files by the root returned for each of them
+func rollupChanges(files []changedFile, root func(file string) string) []changeRollup {
+	var rollup []changeRollup
+	for _, file := range files {
+		dir := root(file.Path)
+		i := slices.IndexFunc(rollup, func(r changeRollup) bool { return r.Path == dir })
+		if i < 0 {
+			rollup = append(rollup, changeRollup{Path: dir})
+			i = len(rollup) - 1
+		}
+		rollup[i].Files = append(rollup[i].Files, file.Path)
+		rollup[i].Additions += file.Additions
+		rollup[i].Deletions += file.Deletions
+	}
+	slices.SortFunc(rollup, func(a, b changeRollup) int { return strings.Compare(a.Path, b.Path) })
+	return rollup
+}
+
+// packageRoot returns the closest package root containing the file - or "." if there are none
+func packageRoot(roots []string, file string) string {
+	for dir := path.Dir(file); dir != "."; dir = path.Dir(dir) {
+		if _, found := slices.BinarySearch(roots, dir); found {
+			return dir
+		}
+	}
+	return "."
+}
+
+// directoryRoot returns the directory of the file, truncated to the given depth
+func directoryRoot(file string, depth int) string {
+	dir := path.Dir(file)
+	if dir == "." {
+		return dir
+	}
+	segments := strings.Split(dir, "/")
+	return strings.Join(segments[:min(depth, len(segments))], "/")
+}
+
+func splitNul(output string) []string {
+	var values []string
+	for _, value := range strings.Split(output, "\x00") {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseChangedFiles(t *testing.T) {
+	nameStatus := "M\x00bin.dat\x00R094\x00a.txt\x00moved.txt\x00D\x00staged.txt\x00A\x00dir with spaces/new.go\x00"
+	numstat := "-\t-\tbin.dat\x001\t0\t\x00a.txt\x00moved.txt\x000\t1\tstaged.txt\x003\t0\tdir with spaces/new.go\x00"
+	files, err := parseChangedFiles(nameStatus, numstat)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []changedFile{
+		{Path: "bin.dat", Status: "modified", Binary: true},
+		{Path: "moved.txt", Status: "renamed", OldPath: "a.txt", Additions: 1},
+		{Path: "staged.txt", Status: "deleted", Deletions: 1},
+		{Path: "dir with spaces/new.go", Status: "added", Additions: 3},
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected files:\n%+v\nexpected:\n%+v", files, expected)
+	}
+
+	for _, tc := range []struct {
+		nameStatus, numstat string
+		expectedErr         string
+	}{
+		{nameStatus: "U\x00conflict.go\x00", expectedErr: `unexpected git diff status "U"`},
+		{nameStatus: "R100\x00a.txt\x00", expectedErr: `missing paths for git diff status "R100"`},
+		{nameStatus: "M\x00", expectedErr: `missing path for git diff status "M"`},
+		{nameStatus: "M\x00a.txt\x00", numstat: "1 0 a.txt\x00", expectedErr: `unexpected git diff numstat "1 0 a.txt"`},
+		{nameStatus: "R100\x00a.txt\x00b.txt\x00", numstat: "0\t0\t\x00a.txt\x00", expectedErr: "missing paths for git diff numstat"},
+		{nameStatus: "M\x00a.txt\x00", numstat: "x\t0\ta.txt\x00", expectedErr: `unexpected git diff numstat "x\t0\ta.txt"`},
+	} {
+		if _, err := parseChangedFiles(tc.nameStatus, tc.numstat); err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+			t.Errorf("%q %q: expected error %q, got %v", tc.nameStatus, tc.numstat, tc.expectedErr, err)
+		}
+	}
+}
+
+func TestParseNumstat(t *testing.T) {
+	stats, err := parseNumstat("-\t-\tlogo.png\x0012\t3\t\x00old/name.go\x00new/name.go\x000\t0\tempty.txt\x00")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]lineStats{
+		"logo.png":    {binary: true},
+		"new/name.go": {additions: 12, deletions: 3},
+		"empty.txt":   {},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("unexpected stats: %+v, expected %+v", stats, expected)
+	}
+}
+
+func TestRollupChanges(t *testing.T) {
+	files := []changedFile{
+		{Path: "README.md", Additions: 1},
+		{Path: "api/go.mod", Additions: 2},
+		{Path: "api/internal/server/server.go", Additions: 10, Deletions: 4},
+		{Path: "api/v2/handler.go", Additions: 3},
+		{Path: "web/src/app.ts", Deletions: 5},
+		{Path: "apis/client.go", Additions: 7},
+	}
+	roots := []string{"api", "api/v2", "web"}
+
+	for _, tc := range []struct {
+		name     string
+		root     func(string) string
+		expected []changeRollup
+	}{
+		{
+			name: "packages",
+			root: func(file string) string { return packageRoot(roots, file) },
+			expected: []changeRollup{
+				{Path: ".", Files: []string{"README.md", "apis/client.go"}, Additions: 8},
+				{Path: "api", Files: []string{"api/go.mod", "api/internal/server/server.go"}, Additions: 12, Deletions: 4},
+				{Path: "api/v2", Files: []string{"api/v2/handler.go"}, Additions: 3},
+				{Path: "web", Files: []string{"web/src/app.ts"}, Deletions: 5},
+			},
+		},
+		{
+			name: "directories",
+			root: func(file string) string { return directoryRoot(file, 2) },
+			expected: []changeRollup{
+				{Path: ".", Files: []string{"README.md"}, Additions: 1},
+				{Path: "api", Files: []string{"api/go.mod"}, Additions: 2},
+				{Path: "api/internal", Files: []string{"api/internal/server/server.go"}, Additions: 10, Deletions: 4},
+				{Path: "api/v2", Files: []string{"api/v2/handler.go"}, Additions: 3},
+				{Path: "apis", Files: []string{"apis/client.go"}, Additions: 7},
+				{Path: "web/src", Files: []string{"web/src/app.ts"}, Deletions: 5},
+			},
+		},
+	} {
+		if rollup := rollupChanges(files, tc.root); !reflect.DeepEqual(rollup, tc.expected) {
+			t.Errorf("%s: unexpected rollup:\n%+v\nexpected:\n%+v", tc.name, rollup, tc.expected)
+		}
+	}
+}
+
+func TestPackageRoot(t *testing.T) {
+	roots := []string{"api", "api/v2", "web/app"}
+	for file, expected := range map[string]string{
+		"main.go":                 ".",
+		"api/main.go":             "api",
+		"api/v2/internal/x.go":    "api/v2",
+		"api/v20/x.go":            "api",
+		"apis/x.go":               ".",
+		"web/app/src/index.ts":    "web/app",
+		"web/other/src/index.ts":  ".",
+		"web/app":                 ".",
+		"web/app/deeply/nested/x": "web/app",
+	} {
+		if actual := packageRoot(roots, file); actual != expected {
+			t.Errorf("unexpected package root of %s: %s, expected %s", file, actual, expected)
+		}
+	}
+}
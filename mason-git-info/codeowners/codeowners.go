@@ -0,0 +1,196 @@
+// Package codeowners parses the CODEOWNERS files, in the GitHub and GitLab syntaxes,
+// and finds the owners of the files of a repository.
+package codeowners
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Locations are the paths of the CODEOWNERS file in a repository, by priority
+var Locations = []string{
+	".github/CODEOWNERS",
+	".gitlab/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+}
+
+// Rule is an entry of a CODEOWNERS file
+type Rule struct {
+	// Section is the GitLab section of the rule - empty for the GitHub syntax
+	Section string
+	Pattern string
+	Owners  []string
+	// Line is the line number of the rule in the file
+	Line int
+	re   *regexp.Regexp
+}
+
+// Match reports whether the rule matches the given path, relative to the root of the repository
+func (r Rule) Match(path string) bool {
+	return r.re.MatchString(strings.TrimPrefix(path, "/"))
+}
+
+// File is a parsed CODEOWNERS file
+type File struct {
+	Rules []Rule
+}
+
+// sectionRe matches the GitLab section headers, such as [Section], ^[Optional section][2] @default-owner
+var sectionRe = regexp.MustCompile(`^\^?\[([^\]]+)\](?:\[\d+\])?(?:\s+(.*))?$`)
+
+// Parse parses the content of a CODEOWNERS file.
+// In a GitLab section, the rules without owners get the default owners of the section.
+func Parse(content string) (*File, error) {
+	var (
+		file          File
+		section       string
+		defaultOwners []string
+	)
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if match := sectionRe.FindStringSubmatch(line); match != nil {
+			section = strings.TrimSpace(match[1])
+			defaultOwners = fields(match[2])
+			continue
+		}
+
+		tokens := fields(line)
+		rule := Rule{
+			Section: section,
+			Pattern: tokens[0],
+			Owners:  tokens[1:],
+			Line:    i + 1,
+		}
+		if len(rule.Owners) == 0 {
+			rule.Owners = defaultOwners
+		}
+		re, err := compilePattern(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CODEOWNERS pattern %q at line %d: %w", rule.Pattern, rule.Line, err)
+		}
+		rule.re = re
+		file.Rules = append(file.Rules, rule)
+	}
+	return &file, nil
+}
+
+// Owners returns the owners of the given path: the owners of the last matching rule of each section.
+// It returns nil if the path has no owners.
+func (f *File) Owners(path string) []string {
+	lastMatches := make(map[string]Rule)
+	var sections []string
+	for _, rule := range f.Rules {
+		if !rule.Match(path) {
+			continue
+		}
+		// the GitLab sections are case-insensitive
+		section := strings.ToLower(rule.Section)
+		if _, found := lastMatches[section]; !found {
+			sections = append(sections, section)
+		}
+		lastMatches[section] = rule
+	}
+
+	var owners []string
+	for _, section := range sections {
+		for _, owner := range lastMatches[section].Owners {
+			if !slices.Contains(owners, owner) {
+				owners = append(owners, owner)
+			}
+		}
+	}
+	return owners
+}
+
+// fields splits a line on the whitespaces which aren't escaped by a backslash, and unescapes them
+func fields(line string) []string {
+	var (
+		tokens  []string
+		current strings.Builder
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			if r != ' ' && r != '\t' && r != '#' {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ' ' || r == '\t':
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// compilePattern converts a gitignore-like pattern to a regular expression matching the paths:
+//   - a pattern starting with, or containing, a slash is relative to the root of the repository,
+//     otherwise it matches at any depth
+//   - a pattern matching a directory matches all its files, but a trailing /* only matches its direct files
+//   - * matches anything but a slash, ** matches any number of directories, and [...] is a character class
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	directory := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var expr strings.Builder
+	if anchored {
+		expr.WriteString("^")
+	} else {
+		expr.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[' && strings.IndexByte(pattern[i+1:], ']') > 0:
+			// character class, such as [Mm] or [!0-9]
+			class := pattern[i+1 : i+1+strings.IndexByte(pattern[i+1:], ']')]
+			if negated, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + negated
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += len(class) + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	switch {
+	case directory:
+		expr.WriteString("/.*$")
+	case strings.HasSuffix(pattern, "/*"):
+		expr.WriteString("$")
+	default:
+		expr.WriteString("(?:/.*)?$")
+	}
+	return regexp.Compile(expr.String())
+}
@@ -0,0 +1,105 @@
+package codeowners
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOwnersGitHub(t *testing.T) {
+	file, err := Parse(`
+# the default owners
+*       @global-owner
+
+*.js    @js-owner
+/build/logs/ @doctocat
+docs/*  docs@example.com
+apps/   @octocat
+/scripts/ @doctocat @octocat
+**/logs @logs-owner
+/config/**/*.yaml @config-owner
+\#notes.md @notes-owner
+/vendor/
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string][]string{
+		"README.md":                      {"@global-owner"},
+		"src/app.js":                     {"@js-owner"},
+		"build/logs/out.txt":             {"@logs-owner"},
+		"build/logs/nested/out.txt":      {"@logs-owner"},
+		"docs/index.md":                  {"docs@example.com"},
+		"docs/guides/setup.md":           {"@global-owner"},
+		"apps/web/main.go":               {"@octocat"},
+		"services/apps/api/main.go":      {"@octocat"},
+		"scripts/release.sh":             {"@doctocat", "@octocat"},
+		"tools/scripts/release.sh":       {"@global-owner"},
+		"deploy/logs":                    {"@logs-owner"},
+		"config/prod/db.yaml":            {"@config-owner"},
+		"config/db.yaml":                 {"@config-owner"},
+		"config/db.json":                 {"@global-owner"},
+		"#notes.md":                      {"@notes-owner"},
+		"vendor/github.com/lib/lib.go":   nil,
+		"/scripts/with-leading-slash.sh": {"@doctocat", "@octocat"},
+	} {
+		if actual := file.Owners(path); !slices.Equal(actual, expected) {
+			t.Errorf("%s: expected owners %v, got %v", path, expected, actual)
+		}
+	}
+}
+
+func TestOwnersGitLab(t *testing.T) {
+	file, err := Parse(`
+* @default
+
+[Documentation] @docs-team
+docs/
+README.md @tech-writer
+
+^[Backend][2] @backend-team
+*.go
+internal/legacy/ @legacy-team
+
+[documentation]
+*.md @md-reviewer
+
+[Escaped]
+path\ with\ spaces/ @spaces-owner
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string][]string{
+		"main.go":                   {"@default", "@backend-team"},
+		"internal/legacy/old.go":    {"@default", "@legacy-team"},
+		"docs/setup.md":             {"@default", "@md-reviewer"},
+		"docs/diagram.png":          {"@default", "@docs-team"},
+		"README.md":                 {"@default", "@md-reviewer"},
+		"path with spaces/file.txt": {"@default", "@spaces-owner"},
+	} {
+		if actual := file.Owners(path); !slices.Equal(actual, expected) {
+			t.Errorf("%s: expected owners %v, got %v", path, expected, actual)
+		}
+	}
+
+	if rule := file.Rules[3]; rule.Section != "Backend" || rule.Pattern != "*.go" || rule.Line != 9 {
+		t.Errorf("unexpected rule %+v", rule)
+	}
+}
+
+func TestParseCharacterClass(t *testing.T) {
+	file, err := Parse("[Mm]akefile @build-owner\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Rules) != 1 || file.Rules[0].Section != "" {
+		t.Fatalf("expected a rule, not a section: %+v", file.Rules)
+	}
+	for path, expected := range map[string]bool{"Makefile": true, "lib/makefile": true, "Rakefile": false} {
+		if actual := file.Rules[0].Match(path); actual != expected {
+			t.Errorf("%s: expected match %v, got %v", path, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"dagger/mason-git-info/internal/dagger"
+)
+
+// commitLintRules are the rules the commit messages are checked against
+type commitLintRules struct {
+	Types               []string
+	ScopePattern        string
+	scopeRe             *regexp.Regexp
+	MaxSubjectLength    int
+	MaxBodyLineLength   int
+	AllowWorkInProgress bool
+	RequireSignOff      bool
+}
+
+// commitLintReport is the result of the commit linting
+type commitLintReport struct {
+	Range      string                `json:"range"`
+	Commits    int                   `json:"commits"`
+	Violations []commitLintViolation `json:"violations"`
+}
+
+// commitLintViolation is a rule broken by a commit message
+type commitLintViolation struct {
+	Commit  string `json:"commit"`
+	Subject string `json:"subject"`
+	// format, type, scope, subject-length, body-wrap, work-in-progress or sign-off
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+var workInProgressRe = regexp.MustCompile(`(?i)^(?:\[?wip\b|(?:fixup|squash|amend)! )`)
+
+// LintCommits checks the message of every commit in a range - merge commits excluded -
+// against the conventional commits format and the given rules.
+// Use the Assert function of the result to fail on violations.
+func (g *MasonGitInfo) LintCommits(
+	ctx context.Context,
+	// start of the range, excluded
+	// +optional
+	// +default="origin/main"
+	from string,
+	// end of the range, included
+	// +optional
+	// +default="HEAD"
+	to string,
+	// allowed conventional commit types
+	// +optional
+	// +default=["feat","fix","perf","revert","refactor","docs","test","build","ci","style","chore"]
+	types []string,
+	// regular expression the scopes must fully match, when set
+	// +optional
+	scopePattern string,
+	// maximum length of the first line - 0 to disable the check
+	// +optional
+	// +default=72
+	maxSubjectLength int,
+	// maximum length of the lines of the body, URLs excluded - 0 to disable the check
+	// +optional
+	// +default=100
+	maxBodyLineLength int,
+	// allow the WIP, fixup!, squash! and amend! commits
+	// +optional
+	allowWorkInProgress bool,
+	// require a Signed-off-by trailer
+	// +optional
+	requireSignOff bool,
+) (*CommitLintRun, error) {
+	rules := commitLintRules{
+		Types:               types,
+		ScopePattern:        scopePattern,
+		MaxSubjectLength:    maxSubjectLength,
+		MaxBodyLineLength:   maxBodyLineLength,
+		AllowWorkInProgress: allowWorkInProgress,
+		RequireSignOff:      requireSignOff,
+	}
+	if scopePattern != "" {
+		var err error
+		if rules.scopeRe, err = regexp.Compile(`^(?:` + scopePattern + `)$`); err != nil {
+			return nil, fmt.Errorf("invalid scope pattern: %w", err)
+		}
+	}
+
+	if err := g.ensureRef(ctx, from); err != nil {
+		return nil, err
+	}
+	revisionRange := from + ".." + to
+	commits, err := g.commits(ctx, revisionRange, "--no-merges")
+	if err != nil {
+		return nil, err
+	}
+	report := commitLintReport{
+		Range:      revisionRange,
+		Commits:    len(commits),
+		Violations: []commitLintViolation{},
+	}
+	for _, c := range commits {
+		report.Violations = append(report.Violations, lintCommit(c, rules)...)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &CommitLintRun{Report: string(data)}, nil
+}
+
+// CommitLintRun is the result of the commit linting
+type CommitLintRun struct {
+	// the JSON report
+	Report string
+}
+
+// Assert fails if a commit message breaks a rule, and returns a summary of the linting
+func (l *CommitLintRun) Assert() (string, error) {
+	var report commitLintReport
+	if err := json.Unmarshal([]byte(l.Report), &report); err != nil {
+		return "", err
+	}
+	if len(report.Violations) == 0 {
+		return fmt.Sprintf("%d commits checked in %s: no violations", report.Commits, report.Range), nil
+	}
+	lines := make([]string, 0, len(report.Violations))
+	for _, v := range report.Violations {
+		lines = append(lines, fmt.Sprintf("%s %q: %s: %s", v.Commit[:min(len(v.Commit), 7)], v.Subject, v.Rule, v.Message))
+	}
+	summary := fmt.Sprintf("%d commits checked in %s: %d violations:\n%s",
+		report.Commits, report.Range, len(report.Violations), strings.Join(lines, "\n"))
+	return summary, fmt.Errorf("commit lint failed: %s", summary)
+}
+
+// ReportFile returns the JSON report of the linting
+func (l *CommitLintRun) ReportFile() *dagger.File {
+	return dag.File("commit-lint.json", l.Report)
+}
+
+// lintCommit returns the rules broken by the message of the given commit
+func lintCommit(c commit, rules commitLintRules) []commitLintViolation {
+	header, _, _ := strings.Cut(c.Message, "\n")
+	var violations []commitLintViolation
+	violation := func(rule, format string, args ...any) {
+		violations = append(violations, commitLintViolation{
+			Commit:  c.Hash,
+			Subject: header,
+			Rule:    rule,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	conventional, ok := parseConventionalCommit(c.Message)
+	switch {
+	case workInProgressRe.MatchString(header):
+		if !rules.AllowWorkInProgress {
+			violation("work-in-progress", "work in progress commits are not allowed")
+		}
+	case !ok:
+		violation("format", "the first line must be \"type(scope): subject\"")
+	default:
+		if len(rules.Types) > 0 && !slices.Contains(rules.Types, conventional.Type) {
+			violation("type", "type %q is not one of %s", conventional.Type, strings.Join(rules.Types, ", "))
+		}
+		if rules.scopeRe != nil && conventional.Scope != "" && !rules.scopeRe.MatchString(conventional.Scope) {
+			violation("scope", "scope %q doesn't match %s", conventional.Scope, rules.ScopePattern)
+		}
+	}
+
+	if length := utf8.RuneCountInString(header); rules.MaxSubjectLength > 0 && length > rules.MaxSubjectLength {
+		violation("subject-length", "the first line has %d characters, more than %d", length, rules.MaxSubjectLength)
+	}
+
+	lines := strings.Split(c.Message, "\n")
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		violation("body-wrap", "the first line must be followed by a blank line")
+	}
+	_, footers := splitFooters(strings.Join(lines[1:], "\n"))
+	if rules.MaxBodyLineLength > 0 {
+		for i, line := range lines[1:] {
+			// the long URLs and the first line of the trailers can't be wrapped
+			if length := utf8.RuneCountInString(line); length > rules.MaxBodyLineLength && !strings.Contains(line, "://") && !isFooterLine(footers, line) {
+				violation("body-wrap", "line %d has %d characters, more than %d", i+2, length, rules.MaxBodyLineLength)
+			}
+		}
+	}
+
+	if rules.RequireSignOff && !slices.ContainsFunc(footers, func(footer string) bool {
+		return strings.HasPrefix(footer, "Signed-off-by: ")
+	}) {
+		violation("sign-off", "the Signed-off-by trailer is missing")
+	}
+	return violations
+}
+
+// isFooterLine reports whether the given line is the first line of one of the footers
+func isFooterLine(footers []string, line string) bool {
+	return slices.ContainsFunc(footers, func(footer string) bool {
+		first, _, _ := strings.Cut(footer, "\n")
+		return first == line
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLintCommit(t *testing.T) {
+	defaults := commitLintRules{
+		Types:             []string{"feat", "fix", "docs", "chore"},
+		MaxSubjectLength:  72,
+		MaxBodyLineLength: 100,
+	}
+	long := strings.Repeat("x", 101)
+	for _, tc := range []struct {
+		name     string
+		message  string
+		rules    func(*commitLintRules)
+		expected []string
+	}{
+		{name: "valid", message: "feat(api): add the endpoint\n\nWith a body.\n\nRefs #12"},
+		{name: "not conventional", message: "Add the endpoint", expected: []string{"format"}},
+		{name: "unknown type", message: "perf(api): faster endpoint", expected: []string{"type"}},
+		{name: "any type", message: "improvement: the api", rules: func(r *commitLintRules) { r.Types = nil }},
+		{
+			name:     "scope",
+			message:  "feat(API): add the endpoint",
+			rules:    func(r *commitLintRules) { r.ScopePattern, r.scopeRe = "[a-z]+", regexp.MustCompile(`^(?:[a-z]+)$`) },
+			expected: []string{"scope"},
+		},
+		{
+			name:    "no scope",
+			message: "feat: add the endpoint",
+			rules:   func(r *commitLintRules) { r.ScopePattern, r.scopeRe = "[a-z]+", regexp.MustCompile(`^(?:[a-z]+)$`) },
+		},
+		{name: "WIP", message: "WIP: the endpoint", expected: []string{"work-in-progress"}},
+		{name: "bracketed WIP", message: "[wip] feat: the endpoint", expected: []string{"work-in-progress"}},
+		{name: "fixup", message: "fixup! feat: add the endpoint", expected: []string{"work-in-progress"}},
+		{name: "squash", message: "squash! feat: add the endpoint", expected: []string{"work-in-progress"}},
+		{name: "wipe is not WIP", message: "wipe the cache", expected: []string{"format"}},
+		{
+			name:    "allowed WIP",
+			message: "fixup! feat: add the endpoint",
+			rules:   func(r *commitLintRules) { r.AllowWorkInProgress = true },
+		},
+		{name: "subject length", message: "feat: " + strings.Repeat("é", 67), expected: []string{"subject-length"}},
+		{name: "max subject length", message: "feat: " + strings.Repeat("é", 66)},
+		{
+			name:    "no subject length",
+			message: "feat: " + strings.Repeat("x", 100),
+			rules:   func(r *commitLintRules) { r.MaxSubjectLength = 0 },
+		},
+		{name: "no blank line", message: "feat: add the endpoint\nWith a body.", expected: []string{"body-wrap"}},
+		{name: "long body line", message: "feat: add the endpoint\n\n" + long, expected: []string{"body-wrap"}},
+		{name: "long URL", message: "feat: add the endpoint\n\nSee https://example.com/" + long},
+		{name: "long trailer", message: "feat: add the endpoint\n\nSome text.\n\nCo-authored-by: " + long},
+		{
+			name:     "long trailer continuation",
+			message:  "feat: add the endpoint\n\nBREAKING CHANGE: the endpoint changed\n" + long,
+			expected: []string{"body-wrap"},
+		},
+		{
+			// a "Word: ..." line of the body is not a trailer
+			name:     "long line like a trailer",
+			message:  "feat: add the endpoint\n\nNote: " + long + "\n\nMore text.",
+			expected: []string{"body-wrap"},
+		},
+		{
+			name:    "no body line length",
+			message: "feat: add the endpoint\n\n" + long,
+			rules:   func(r *commitLintRules) { r.MaxBodyLineLength = 0 },
+		},
+		{
+			name:    "sign-off",
+			message: "feat: add the endpoint\n\nWith a body.\n\nSigned-off-by: Jane <jane@example.com>",
+			rules:   func(r *commitLintRules) { r.RequireSignOff = true },
+		},
+		{
+			name:     "missing sign-off",
+			message:  "feat: add the endpoint",
+			rules:    func(r *commitLintRules) { r.RequireSignOff = true },
+			expected: []string{"sign-off"},
+		},
+		{
+			name:     "sign-off in the body",
+			message:  "feat: add the endpoint\n\nSigned-off-by: Jane <jane@example.com>\n\nMore text.",
+			rules:    func(r *commitLintRules) { r.RequireSignOff = true },
+			expected: []string{"sign-off"},
+		},
+		{
+			name:     "many violations",
+			message:  "Add the endpoint " + strings.Repeat("x", 60) + "\nWith a body.",
+			rules:    func(r *commitLintRules) { r.RequireSignOff = true },
+			expected: []string{"format", "subject-length", "body-wrap", "sign-off"},
+		},
+	} {
+		rules := defaults
+		if tc.rules != nil {
+			tc.rules(&rules)
+		}
+		var actual []string
+		for _, v := range lintCommit(commit{Hash: "abc", Message: tc.message}, rules) {
+			actual = append(actual, v.Rule)
+			if header, _, _ := strings.Cut(tc.message, "\n"); v.Commit != "abc" || v.Subject != header || v.Message == "" {
+				t.Errorf("%s: unexpected violation %+v", tc.name, v)
+			}
+		}
+		if !slices.Equal(actual, tc.expected) {
+			t.Errorf("%s: expected violations %q, got %q", tc.name, tc.expected, actual)
+		}
+	}
+}
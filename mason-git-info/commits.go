@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// commit is a commit of the git log
+type commit struct {
+	Hash        string
+	AuthorName  string
+	AuthorEmail string
+	Message     string
+}
+
+// commits returns the commits of the given revision range, such as "v1.0.0..HEAD", newest first.
+// The extra arguments are git log options, such as "--no-merges".
+func (g *MasonGitInfo) commits(ctx context.Context, revisionRange string, extraArgs ...string) ([]commit, error) {
+	output, err := g.Container.WithExec(slices.Concat([]string{
+		"git", "log", "--format=%H%x1f%an%x1f%ae%x1f%B%x1e",
+	}, extraArgs, []string{revisionRange, "--"})).Stdout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var commits []commit
+	for _, record := range strings.Split(output, "\x1e") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x1f", 4)
+		if len(fields) < 4 {
+			continue
+		}
+		commits = append(commits, commit{
+			Hash:        fields[0],
+			AuthorName:  fields[1],
+			AuthorEmail: fields[2],
+			Message:     strings.TrimRight(fields[3], "\n"),
+		})
+	}
+	return commits, nil
+}
+
+// conventionalCommit is a commit message following https://www.conventionalcommits.org/
+type conventionalCommit struct {
+	Type     string
+	Scope    string
+	Breaking bool
+	Subject  string
+	Body     string
+	// the footers, such as "Signed-off-by: name <email>", as written
+	Footers []string
+}
+
+var (
+	conventionalHeaderRe = regexp.MustCompile(`^(\w[\w-]*)(?:\(([^()]*)\))?(!)?: (.*)$`)
+	footerRe             = regexp.MustCompile(`^(?:BREAKING CHANGE|[\w-]+)(?:: | #)`)
+)
+
+// parseConventionalCommit parses the given commit message.
+// It returns false if the header doesn't follow the conventional commits format.
+func parseConventionalCommit(message string) (conventionalCommit, bool) {
+	header, rest, _ := strings.Cut(message, "\n")
+	match := conventionalHeaderRe.FindStringSubmatch(strings.TrimSpace(header))
+	if match == nil {
+		return conventionalCommit{Subject: strings.TrimSpace(header), Body: strings.TrimSpace(rest)}, false
+	}
+	c := conventionalCommit{
+		Type:     strings.ToLower(match[1]),
+		Scope:    match[2],
+		Breaking: match[3] == "!",
+		Subject:  match[4],
+	}
+
+	c.Body, c.Footers = splitFooters(rest)
+	for _, footer := range c.Footers {
+		if strings.HasPrefix(footer, "BREAKING CHANGE: ") || strings.HasPrefix(footer, "BREAKING-CHANGE: ") {
+			c.Breaking = true
+		}
+	}
+	return c, true
+}
+
+// splitFooters splits the given message body - after the first line - into its text and its footers:
+// the footers are the trailing paragraph, if it starts with a footer, each with its continuation lines
+func splitFooters(body string) (string, []string) {
+	var footers []string
+	paragraphs := strings.Split(strings.TrimSpace(body), "\n\n")
+	if last := paragraphs[len(paragraphs)-1]; last != "" && footerRe.MatchString(last) {
+		for _, line := range strings.Split(last, "\n") {
+			if footerRe.MatchString(line) || len(footers) == 0 {
+				footers = append(footers, line)
+			} else {
+				footers[len(footers)-1] += "\n" + line
+			}
+		}
+		paragraphs = paragraphs[:len(paragraphs)-1]
+	}
+	return strings.Join(paragraphs, "\n\n"), footers
+}
+
+// breakingChange returns the description of the breaking change - or the subject if there are none
+func (c conventionalCommit) breakingChange() string {
+	for _, footer := range c.Footers {
+		for _, token := range []string{"BREAKING CHANGE: ", "BREAKING-CHANGE: "} {
+			if description, ok := strings.CutPrefix(footer, token); ok {
+				return description
+			}
+		}
+	}
+	return c.Subject
+}
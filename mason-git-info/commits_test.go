@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseConventionalCommit(t *testing.T) {
+	for _, tc := range []struct {
+		message      string
+		expected     conventionalCommit
+		conventional bool
+	}{
+		{
+			message:      "feat: add the changelog",
+			expected:     conventionalCommit{Type: "feat", Subject: "add the changelog"},
+			conventional: true,
+		},
+		{
+			message:      "Fix(api)!: drop the v1 endpoints\n\nThey were deprecated.",
+			expected:     conventionalCommit{Type: "fix", Scope: "api", Breaking: true, Subject: "drop the v1 endpoints", Body: "They were deprecated."},
+			conventional: true,
+		},
+		{
+			message: "fix: the parser\n\nFirst paragraph.\n\nSecond paragraph.\n\nReviewed-by: Jane\nRefs #12\nSigned-off-by: John <john@example.com>",
+			expected: conventionalCommit{
+				Type:    "fix",
+				Subject: "the parser",
+				Body:    "First paragraph.\n\nSecond paragraph.",
+				Footers: []string{"Reviewed-by: Jane", "Refs #12", "Signed-off-by: John <john@example.com>"},
+			},
+			conventional: true,
+		},
+		{
+			message: "refactor: the config\n\nBREAKING CHANGE: the options are renamed\nand the defaults changed",
+			expected: conventionalCommit{
+				Type:     "refactor",
+				Breaking: true,
+				Subject:  "the config",
+				Footers:  []string{"BREAKING CHANGE: the options are renamed\nand the defaults changed"},
+			},
+			conventional: true,
+		},
+		{
+			message: "chore: deps\n\nBREAKING-CHANGE: requires go 1.24",
+			expected: conventionalCommit{
+				Type:     "chore",
+				Breaking: true,
+				Subject:  "deps",
+				Footers:  []string{"BREAKING-CHANGE: requires go 1.24"},
+			},
+			conventional: true,
+		},
+		{
+			// a footer must be in the last paragraph
+			message:      "docs: readme\n\nSigned-off-by: John\n\nMore details.",
+			expected:     conventionalCommit{Type: "docs", Subject: "readme", Body: "Signed-off-by: John\n\nMore details."},
+			conventional: true,
+		},
+		{
+			// "BREAKING CHANGE" must be a footer
+			message:      "docs: explain the BREAKING CHANGE: policy",
+			expected:     conventionalCommit{Type: "docs", Subject: "explain the BREAKING CHANGE: policy"},
+			conventional: true,
+		},
+		{
+			message:  "Update the readme\n\nWith more details.",
+			expected: conventionalCommit{Subject: "Update the readme", Body: "With more details."},
+		},
+		{
+			message:  "feat:missing space",
+			expected: conventionalCommit{Subject: "feat:missing space"},
+		},
+		{
+			message:  "feat(nested (scope)): subject",
+			expected: conventionalCommit{Subject: "feat(nested (scope)): subject"},
+		},
+	} {
+		actual, conventional := parseConventionalCommit(tc.message)
+		if conventional != tc.conventional || !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%q: expected %+v %t, got %+v %t", tc.message, tc.expected, tc.conventional, actual, conventional)
+		}
+	}
+}
+
+func TestBreakingChange(t *testing.T) {
+	for message, expected := range map[string]string{
+		"feat!: drop the v1 API":                              "drop the v1 API",
+		"fix: the config\n\nBREAKING CHANGE: renamed options": "renamed options",
+		"fix: the config\n\nBREAKING-CHANGE: renamed options": "renamed options",
+	} {
+		c, _ := parseConventionalCommit(message)
+		if actual := c.breakingChange(); actual != expected {
+			t.Errorf("%q: expected %q, got %q", message, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"dagger/mason-git-info/internal/dagger"
+)
+
+// MergeBaseDiffFile returns the diff of the changes since the merge-base of HEAD and the base branch,
+// as a patch file: the committed changes - without the unrelated commits of the base branch -
+// and optionally the staged, unstaged and untracked changes - the last two only with a worktree.
+func (g *MasonGitInfo) MergeBaseDiffFile(
+	ctx context.Context,
+	// base branch the changes are compared to
+	// +optional
+	// +default="origin/main"
+	base string,
+	// include the staged changes
+	// +optional
+	// +default=true
+	staged bool,
+	// include the unstaged changes
+	// +optional
+	// +default=true
+	unstaged bool,
+	// include the untracked files
+	// +optional
+	// +default=true
+	untracked bool,
+	// pathspecs of the files to include - all the files by default
+	// +optional
+	include []string,
+	// pathspecs of the files to exclude
+	// +optional
+	exclude []string,
+	// number of context lines around the changes
+	// +optional
+	// +default=3
+	contextLines int,
+) (*dagger.File, error) {
+	mergeBase, err := g.mergeBase(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+
+	worktree, err := g.insideWorkTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !worktree {
+		// given the .git directory itself: there are no unstaged nor untracked changes
+		unstaged, untracked = false, false
+	}
+
+	diffOptions := []string{"--no-color", "-U" + strconv.Itoa(contextLines)}
+	pathspecs := diffPathspecs(include, exclude)
+	// the revisions of each diff
+	var diffs [][]string
+	switch {
+	case staged && unstaged:
+		// the worktree compared to the merge-base: committed, staged and unstaged changes
+		diffs = [][]string{{mergeBase}}
+	case staged:
+		diffs = [][]string{{"--cached", mergeBase}}
+	case unstaged:
+		diffs = [][]string{{mergeBase, "HEAD"}, {}}
+	default:
+		diffs = [][]string{{mergeBase, "HEAD"}}
+	}
+
+	var fullDiff strings.Builder
+	for _, revisions := range diffs {
+		diff, err := g.Container.WithExec(slices.Concat([]string{"git", "diff"}, diffOptions, revisions, []string{"--"}, pathspecs)).Stdout(ctx)
+		if err != nil {
+			return nil, err
+		}
+		fullDiff.WriteString(diff)
+	}
+
+	if untracked {
+		diff, err := g.untrackedDiff(ctx, diffOptions, pathspecs)
+		if err != nil {
+			return nil, err
+		}
+		fullDiff.WriteString(diff)
+	}
+
+	return dag.File("git-diff", fullDiff.String()), nil
+}
+
+// untrackedDiff returns the git diff of the untracked files matching the given pathspecs, as new files,
+// with the given diff options. It runs a single git diff, with the untracked files marked as intent-to-add
+// in a copy of the index - to keep the one of the repository untouched.
+func (g *MasonGitInfo) untrackedDiff(ctx context.Context, diffOptions, pathspecs []string) (string, error) {
+	const (
+		untrackedList  = "/tmp/untracked.list"
+		untrackedIndex = "/tmp/untracked.index"
+	)
+	return g.Container.
+		WithExec(append([]string{"git", "ls-files", "--others", "--exclude-standard", "-z", "--"}, pathspecs...),
+			dagger.ContainerWithExecOpts{RedirectStdout: untrackedList}).
+		WithExec([]string{"sh", "-c", `cp "$(git rev-parse --git-path index)" ` + untrackedIndex}).
+		WithEnvVariable("GIT_INDEX_FILE", untrackedIndex).
+		WithExec([]string{
+			"git", "--literal-pathspecs", "add", "--intent-to-add",
+			"--pathspec-from-file=" + untrackedList, "--pathspec-file-nul",
+		}).
+		// only the intent-to-add files are added, compared to the index
+		WithExec(slices.Concat([]string{"git", "diff", "--no-renames", "--diff-filter=A"}, diffOptions)).
+		Stdout(ctx)
+}
+
+// insideWorkTree returns true if the git directory has a worktree - and not only the .git directory
+func (g *MasonGitInfo) insideWorkTree(ctx context.Context) (bool, error) {
+	output, err := g.Container.WithExec([]string{
+		"git", "rev-parse", "--is-inside-work-tree",
+	}, dagger.ContainerWithExecOpts{
+		Expect: dagger.ReturnTypeAny,
+	}).Stdout(ctx)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) == "true", nil
+}
+
+// mergeBase returns the SHA of the best common ancestor of HEAD and the given base ref
+func (g *MasonGitInfo) mergeBase(ctx context.Context, base string) (string, error) {
+	if err := g.ensureRef(ctx, base); err != nil {
+		return "", err
+	}
+	mergeBase, err := g.Container.WithExec([]string{
+		"git", "merge-base", base, "HEAD",
+	}, dagger.ContainerWithExecOpts{
+		Expect: dagger.ReturnTypeAny,
+	}).Stdout(ctx)
+	if err != nil {
+		return "", err
+	}
+	mergeBase = strings.TrimSpace(mergeBase)
+	if mergeBase == "" {
+		return "", fmt.Errorf("no merge-base found between HEAD and %q: the base ref may be missing", base)
+	}
+	return mergeBase, nil
+}
+
+// diffPathspecs returns the git pathspecs matching the included files - all by default - without the excluded files
+func diffPathspecs(include, exclude []string) []string {
+	var pathspecs []string
+	for _, pattern := range include {
+		pathspecs = append(pathspecs, ":(glob)"+pattern)
+	}
+	if len(pathspecs) == 0 && len(exclude) > 0 {
+		pathspecs = append(pathspecs, ".")
+	}
+	for _, pattern := range exclude {
+		pathspecs = append(pathspecs, ":(glob,exclude)"+pattern)
+	}
+	return pathspecs
+}
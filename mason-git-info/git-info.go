@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
+	"dagger/mason-git-info/gitrepo"
 	"dagger/mason-git-info/internal/dagger"
 )
 
@@ -18,8 +20,10 @@ const (
 
 // GitInfo contains information about a git reference
 type MasonGitInfo struct {
-	GitDirectory *dagger.Directory
-	Container    *dagger.Container
+	GitDirectory     *dagger.Directory
+	Container        *dagger.Container
+	FetchMissingRefs bool
+	CIEnv            []string
 }
 
 // New returns a new GitInfo instance with information about the git reference
@@ -34,6 +38,14 @@ func New(
 	// default to cgr.dev/chainguard/wolfi-base:latest with git installed
 	// +optional
 	gitBaseContainer *dagger.Container,
+	// fetch the missing refs - and deepen the shallow clones - when a remote is reachable
+	// instead of failing, such as in the CI checkouts
+	// +optional
+	fetchMissingRefs bool,
+	// environment variables of the CI, as KEY=VALUE, to find the branch name of a detached HEAD
+	// such as GITHUB_HEAD_REF, CI_COMMIT_BRANCH or BRANCH_NAME
+	// +optional
+	ciEnv []string,
 ) *MasonGitInfo {
 	ctr := gitBaseContainer
 	if ctr == nil {
@@ -53,21 +65,22 @@ func New(
 		WithExec([]string{"git", "config", "--global", "--add", "safe.directory", "/workdir"})
 
 	return &MasonGitInfo{
-		GitDirectory: gitDirectory,
-		Container:    ctr,
+		GitDirectory:     gitDirectory,
+		Container:        ctr,
+		FetchMissingRefs: fetchMissingRefs,
+		CIEnv:            ciEnv,
 	}
 }
 
-func (g *MasonGitInfo) InfoFile() *dagger.File {
-	return dag.GitInfo(g.GitDirectory).JSONFile()
+// IsShallow reports whether the repository is a shallow clone
+func (g *MasonGitInfo) IsShallow(ctx context.Context) (bool, error) {
+	return gitrepo.IsShallow(ctx, g.git)
 }
 
+// BranchName returns the name of the current branch - from the CI environment variables first,
+// because the CI checkouts are usually detached, then from HEAD or the branches pointing to it
 func (g *MasonGitInfo) BranchName(ctx context.Context) (string, error) {
-	return dag.GitInfo(g.GitDirectory).Branch(ctx)
-}
-
-func (g *MasonGitInfo) RepoURL(ctx context.Context) (string, error) {
-	return dag.GitInfo(g.GitDirectory).URL(ctx)
+	return gitrepo.BranchName(ctx, g.git, g.lookupEnv)
 }
 
 func (g *MasonGitInfo) DiffFile(
@@ -78,11 +91,15 @@ func (g *MasonGitInfo) DiffFile(
 ) (*dagger.File, error) {
 	var fullDiff string
 
-	diffFromOrigin, err := g.Container.WithExec(append([]string{
-		"git", "diff",
-	}, targets...), dagger.ContainerWithExecOpts{
-		Expect: dagger.ReturnTypeAny,
-	}).Stdout(ctx)
+	for _, target := range targets {
+		if strings.HasPrefix(target, "-") || strings.Contains(target, "..") {
+			continue
+		}
+		if err := g.ensureRef(ctx, target); err != nil {
+			return nil, err
+		}
+	}
+	diffFromOrigin, err := g.git(ctx, append([]string{"diff"}, targets...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -125,3 +142,35 @@ func (g *MasonGitInfo) RawCmdAsFile(args []string) *dagger.File {
 		}).
 		File("/tmp/stdout")
 }
+
+// ensureRef makes sure the given ref is available - and its merge-base with HEAD, in a shallow clone -
+// fetching them if enabled
+func (g *MasonGitInfo) ensureRef(ctx context.Context, ref string) error {
+	return gitrepo.EnsureRef(ctx, g.git, ref, g.FetchMissingRefs)
+}
+
+// git runs a git command in the container, and keeps its changes - such as the fetched refs - for the next commands
+func (g *MasonGitInfo) git(ctx context.Context, args ...string) (string, error) {
+	ctr := g.Container.WithExec(append([]string{"git"}, args...), dagger.ContainerWithExecOpts{
+		Expect: dagger.ReturnTypeAny,
+	})
+	exitCode, err := ctr.ExitCode(ctx)
+	if err != nil {
+		return "", err
+	}
+	if exitCode != 0 {
+		stderr, _ := ctr.Stderr(ctx) //nolint:errcheck // best effort
+		return "", fmt.Errorf("git %s failed with exit code %d: %s", strings.Join(args, " "), exitCode, strings.TrimSpace(stderr))
+	}
+	g.Container = ctr
+	return ctr.Stdout(ctx)
+}
+
+func (g *MasonGitInfo) lookupEnv(name string) (string, bool) {
+	for _, env := range g.CIEnv {
+		if key, value, ok := strings.Cut(env, "="); ok && key == name {
+			return value, true
+		}
+	}
+	return "", false
+}
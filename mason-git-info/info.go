@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"dagger/mason-git-info/gitrepo"
+	"dagger/mason-git-info/internal/dagger"
+)
+
+// infoSchemaVersion is the version of the JSON written by InfoFile,
+// incremented on every breaking change of GitMetadata
+const infoSchemaVersion = 1
+
+// GitMetadata contains the metadata of the current commit of the repository
+type GitMetadata struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Commit        string `json:"commit"`
+	ShortCommit   string `json:"shortCommit"`
+	// Branch is empty for a detached HEAD without branch
+	Branch string `json:"branch"`
+	// DefaultBranch is the default branch of the origin remote - empty if unknown
+	DefaultBranch string      `json:"defaultBranch"`
+	Tags          []string    `json:"tags"`
+	Dirty         bool        `json:"dirty"`
+	Author        GitPerson   `json:"author"`
+	Committer     GitPerson   `json:"committer"`
+	Message       string      `json:"message"`
+	Remotes       []GitRemote `json:"remotes"`
+	// URL is the URL of the origin remote - or the first remote - without credentials
+	URL string `json:"url"`
+}
+
+// GitPerson is the author or the committer of a commit
+type GitPerson struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	// Date is in the strict ISO 8601 format
+	Date string `json:"date"`
+}
+
+// GitRemote is a remote of the repository, with its URL stripped of any credentials
+type GitRemote struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// Metadata returns the metadata of the current commit
+func (g *MasonGitInfo) Metadata(ctx context.Context) (*GitMetadata, error) {
+	log, err := g.git(ctx, "log", "-1", "--format=%H%x00%h%x00%an%x00%ae%x00%aI%x00%cn%x00%ce%x00%cI%x00%B", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.SplitN(log, "\x00", 9)
+	if len(fields) != 9 {
+		return nil, fmt.Errorf("unexpected output of git log: %q", log)
+	}
+	metadata := &GitMetadata{
+		SchemaVersion: infoSchemaVersion,
+		Commit:        fields[0],
+		ShortCommit:   fields[1],
+		Author:        GitPerson{Name: fields[2], Email: fields[3], Date: fields[4]},
+		Committer:     GitPerson{Name: fields[5], Email: fields[6], Date: fields[7]},
+		Message:       strings.TrimSpace(fields[8]),
+	}
+
+	if metadata.Branch, err = g.BranchName(ctx); err != nil && !errors.Is(err, gitrepo.ErrDetachedHead) {
+		return nil, err
+	}
+	if metadata.DefaultBranch, err = g.DefaultBranch(ctx); err != nil {
+		return nil, err
+	}
+	if metadata.Tags, err = g.Tags(ctx); err != nil {
+		return nil, err
+	}
+	if metadata.Dirty, err = g.IsDirty(ctx); err != nil {
+		return nil, err
+	}
+	if metadata.Remotes, err = g.Remotes(ctx); err != nil {
+		return nil, err
+	}
+	metadata.URL = originURL(metadata.Remotes)
+	return metadata, nil
+}
+
+// InfoFile returns the metadata of the current commit as a JSON file.
+// Its schemaVersion field is incremented on every breaking change.
+func (g *MasonGitInfo) InfoFile(ctx context.Context) (*dagger.File, error) {
+	metadata, err := g.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return dag.File("git-info.json", string(data)+"\n"), nil
+}
+
+// CommitSha returns the SHA of the current commit
+func (g *MasonGitInfo) CommitSha(ctx context.Context) (string, error) {
+	return g.revParse(ctx, "HEAD")
+}
+
+// ShortSha returns the abbreviated SHA of the current commit
+func (g *MasonGitInfo) ShortSha(ctx context.Context) (string, error) {
+	return g.revParse(ctx, "--short", "HEAD")
+}
+
+// Tag returns the tag of the current commit - or an empty string if it isn't tagged.
+// If there are several tags, the highest version wins.
+func (g *MasonGitInfo) Tag(ctx context.Context) (string, error) {
+	tags, err := g.Tags(ctx)
+	if err != nil || len(tags) == 0 {
+		return "", err
+	}
+	return tags[0], nil
+}
+
+// Tags returns the tags of the current commit, from the highest version
+func (g *MasonGitInfo) Tags(ctx context.Context) ([]string, error) {
+	tags, err := g.git(ctx, "tag", "--points-at", "HEAD", "--sort=-version:refname")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(tags), nil
+}
+
+// Author returns the author of the current commit
+func (g *MasonGitInfo) Author(ctx context.Context) (*GitPerson, error) {
+	return g.person(ctx, "a")
+}
+
+// Committer returns the committer of the current commit
+func (g *MasonGitInfo) Committer(ctx context.Context) (*GitPerson, error) {
+	return g.person(ctx, "c")
+}
+
+// Date returns the commit date of the current commit, in the strict ISO 8601 format
+func (g *MasonGitInfo) Date(ctx context.Context) (string, error) {
+	date, err := g.git(ctx, "log", "-1", "--format=%cI", "HEAD")
+	return strings.TrimSpace(date), err
+}
+
+// Message returns the full message of the current commit
+func (g *MasonGitInfo) Message(ctx context.Context) (string, error) {
+	message, err := g.git(ctx, "log", "-1", "--format=%B", "HEAD")
+	return strings.TrimSpace(message), err
+}
+
+// IsDirty reports whether the worktree has uncommitted changes - untracked files included
+func (g *MasonGitInfo) IsDirty(ctx context.Context) (bool, error) {
+	status, err := g.git(ctx, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(status) != "", nil
+}
+
+// DefaultBranch returns the default branch of the origin remote - or an empty string if unknown
+func (g *MasonGitInfo) DefaultBranch(ctx context.Context) (string, error) {
+	remotes, err := g.Remotes(ctx)
+	if err != nil || len(remotes) == 0 {
+		return "", err
+	}
+	remote := remotes[0].Name
+	for _, r := range remotes {
+		if r.Name == "origin" {
+			remote = r.Name
+		}
+	}
+	// missing in most CI checkouts
+	head, err := g.git(ctx, "symbolic-ref", "--quiet", "--short", "refs/remotes/"+remote+"/HEAD")
+	if err != nil {
+		return "", nil
+	}
+	return strings.TrimPrefix(strings.TrimSpace(head), remote+"/"), nil
+}
+
+// Remotes returns the remotes of the repository, with their URLs stripped of any credentials
+func (g *MasonGitInfo) Remotes(ctx context.Context) ([]GitRemote, error) {
+	names, err := g.git(ctx, "remote")
+	if err != nil {
+		return nil, err
+	}
+	remotes := []GitRemote{}
+	for _, name := range strings.Fields(names) {
+		url, err := g.git(ctx, "remote", "get-url", name)
+		if err != nil {
+			return nil, err
+		}
+		remotes = append(remotes, GitRemote{
+			Name: name,
+			URL:  gitrepo.StripCredentials(strings.TrimSpace(url)),
+		})
+	}
+	return remotes, nil
+}
+
+// RepoURL returns the URL of the origin remote - or the first remote - without credentials
+func (g *MasonGitInfo) RepoURL(ctx context.Context) (string, error) {
+	remotes, err := g.Remotes(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(remotes) == 0 {
+		return "", errors.New("the git repository has no remotes")
+	}
+	return originURL(remotes), nil
+}
+
+func originURL(remotes []GitRemote) string {
+	for _, remote := range remotes {
+		if remote.Name == "origin" {
+			return remote.URL
+		}
+	}
+	if len(remotes) > 0 {
+		return remotes[0].URL
+	}
+	return ""
+}
+
+func (g *MasonGitInfo) revParse(ctx context.Context, args ...string) (string, error) {
+	sha, err := g.git(ctx, append([]string{"rev-parse"}, args...)...)
+	return strings.TrimSpace(sha), err
+}
+
+// person returns the author - "a" - or the committer - "c" - of the current commit
+func (g *MasonGitInfo) person(ctx context.Context, role string) (*GitPerson, error) {
+	log, err := g.git(ctx, "log", "-1", "--format=%"+role+"n%x00%"+role+"e%x00%"+role+"I", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Split(strings.TrimSpace(log), "\x00")
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("unexpected output of git log: %q", log)
+	}
+	return &GitPerson{Name: fields[0], Email: fields[1], Date: fields[2]}, nil
+}
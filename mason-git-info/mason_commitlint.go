@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+
+	"github.com/vbehar/mason-modules/brickspec"
+	"github.com/vbehar/mason-sdk-go"
+)
+
+type CommitLintSpec struct {
+	GitDirectory        string               `json:"gitDirectory" default:"." description:"Path of the git repository on the host."`
+	From                string               `json:"from" default:"origin/main" description:"Start of the range of the commits to check, excluded."`
+	To                  string               `json:"to" default:"HEAD" description:"End of the range of the commits to check, included."`
+	Types               []string             `json:"types" description:"Allowed conventional commit types - default to feat, fix, perf, revert, refactor, docs, test, build, ci, style and chore."`
+	ScopePattern        string               `json:"scopePattern" description:"Regular expression the scopes must fully match."`
+	MaxSubjectLength    *int                 `json:"maxSubjectLength" default:"72" description:"Maximum length of the first line - 0 to disable the check."`
+	MaxBodyLineLength   *int                 `json:"maxBodyLineLength" default:"100" description:"Maximum length of the lines of the body, URLs excluded - 0 to disable the check."`
+	AllowWorkInProgress bool                 `json:"allowWorkInProgress" description:"Allow the WIP, fixup!, squash! and amend! commits."`
+	RequireSignOff      bool                 `json:"requireSignOff" description:"Require a Signed-off-by trailer."`
+	FetchMissingRefs    bool                 `json:"fetchMissingRefs" description:"Fetch the missing refs - and deepen the shallow clones - when a remote is reachable, such as in the CI checkouts."`
+	CIEnv               []string             `json:"ciEnv" description:"Environment variables of the CI, as KEY=VALUE, to find the branch name of a detached HEAD - default to the branch variables of the common CI systems, from the host."`
+	Output              CommitLintSpecOutput `json:"output" description:"Where to write the lint report."`
+}
+
+type CommitLintSpecOutput struct {
+	ReportDaggerFileName string `json:"reportDaggerFileName" validate:"identifier" description:"Name of the dagger variable holding the JSON report, for use by other bricks."`
+	ReportHostFilePath   string `json:"reportHostFilePath" description:"Path on the host where to write the JSON report."`
+}
+
+func (s CommitLintSpec) Validate() error {
+	var errs []error
+	if _, err := regexp.Compile(s.ScopePattern); err != nil {
+		errs = append(errs, brickspec.Errorf("scopePattern", "invalid regular expression: %v", err))
+	}
+	if s.MaxSubjectLength != nil && *s.MaxSubjectLength < 0 {
+		errs = append(errs, brickspec.Errorf("maxSubjectLength", "must not be negative"))
+	}
+	if s.MaxBodyLineLength != nil && *s.MaxBodyLineLength < 0 {
+		errs = append(errs, brickspec.Errorf("maxBodyLineLength", "must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+func (s CommitLintSpec) Plan(brick mason.Brick) map[string]string {
+	plan := map[string]string{
+		"lint_" + brick.Filename(): s.lintScript(brick),
+	}
+	for _, phase := range brick.Metadata.ExtraPhases {
+		plan[phase+"_"+brick.Filename()] = plan["lint_"+brick.Filename()]
+	}
+	return plan
+}
+
+func (s CommitLintSpec) Artifacts() brickspec.Artifacts {
+	return brickspec.Artifacts{
+		Inputs:   []string{s.GitDirectory},
+		Outputs:  []string{s.Output.ReportHostFilePath},
+		Produces: []string{s.Output.ReportDaggerFileName},
+	}
+}
+
+func (s CommitLintSpec) lintScript(brick mason.Brick) string {
+	baseCmd := func() *brickspec.Cmd {
+		cmd := brickspec.GitInfoCommand(brick.ModuleRef, s.GitDirectory, s.FetchMissingRefs, s.CIEnv).
+			Pipe("lint-commits").
+			Flag("from", s.From).
+			Flag("to", s.To).
+			ListFlag("types", s.Types).
+			Flag("scope-pattern", s.ScopePattern)
+		if s.MaxSubjectLength != nil {
+			cmd.Flag("max-subject-length", strconv.Itoa(*s.MaxSubjectLength))
+		}
+		if s.MaxBodyLineLength != nil {
+			cmd.Flag("max-body-line-length", strconv.Itoa(*s.MaxBodyLineLength))
+		}
+		return cmd.
+			BoolFlag("allow-work-in-progress", s.AllowWorkInProgress).
+			BoolFlag("require-sign-off", s.RequireSignOff)
+	}
+
+	var script brickspec.Script
+	switch {
+	case s.Output.ReportDaggerFileName != "":
+		script.Assign(s.Output.ReportDaggerFileName, baseCmd().Pipe("report-file"))
+		if s.Output.ReportHostFilePath != "" {
+			script.Run(brickspec.FromRef(brickspec.Var(s.Output.ReportDaggerFileName)).Pipe("export", s.Output.ReportHostFilePath))
+		}
+	case s.Output.ReportHostFilePath != "":
+		script.Run(baseCmd().Pipe("report-file").Pipe("export", s.Output.ReportHostFilePath))
+	}
+	script.Echo("")
+	script.Run(baseCmd().Pipe("assert"))
+
+	return script.String()
+}
@@ -1,22 +1,95 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"strconv"
 
+	"github.com/vbehar/mason-modules/brickspec"
 	"github.com/vbehar/mason-sdk-go"
 )
 
 type GitInfoSpec struct {
-	GitDirectory string              `json:"gitDirectory"`
-	Outputs      []GitInfoSpecOutput `json:"outputs"`
+	GitDirectory     string              `json:"gitDirectory" default:"." description:"Path of the git repository on the host."`
+	Outputs          []GitInfoSpecOutput `json:"outputs" validate:"required" description:"Git information to export."`
+	FetchMissingRefs bool                `json:"fetchMissingRefs" description:"Fetch the missing refs - and deepen the shallow clones - when a remote is reachable, such as in the CI checkouts."`
+	CIEnv            []string            `json:"ciEnv" description:"Environment variables of the CI, as KEY=VALUE, to find the branch name of a detached HEAD - default to the branch variables of the common CI systems, from the host."`
 }
 
 type GitInfoSpecOutput struct {
-	DaggerFileName string   `json:"daggerFileName"`
-	HostFilePath   string   `json:"hostFilePath"`
-	RawCmd         []string `json:"rawCmd"`
-	Type           string   `json:"type"`
+	DaggerFileName string                        `json:"daggerFileName" validate:"required,identifier" description:"Name of the dagger variable holding the output file, for use by other bricks."`
+	HostFilePath   string                        `json:"hostFilePath" description:"Path on the host where to write the output file."`
+	RawCmd         []string                      `json:"rawCmd" description:"Command to run in the git container, for the raw type."`
+	Type           string                        `json:"type" default:"raw" enum:"diff,mergeBaseDiff,changedFiles,version,changelog,ownership,archive,info,raw" description:"Type of output: diff, mergeBaseDiff, changedFiles, version, changelog, ownership, archive, info, or the stdout of a raw command."`
+	Diff           GitInfoSpecOutputDiff         `json:"diff" description:"Options of the mergeBaseDiff type."`
+	ChangedFiles   GitInfoSpecOutputChangedFiles `json:"changedFiles" description:"Options of the changedFiles type."`
+	Version        GitInfoSpecOutputVersion      `json:"version" description:"Options of the version type."`
+	Changelog      GitInfoSpecOutputChangelog    `json:"changelog" description:"Options of the changelog type."`
+	Ownership      GitInfoSpecOutputOwnership    `json:"ownership" description:"Options of the ownership type."`
+	Archive        GitInfoSpecOutputArchive      `json:"archive" description:"Options of the archive type."`
+}
+
+// GitInfoSpecOutputDiff are the options of the diff computed from the merge-base of a base branch
+type GitInfoSpecOutputDiff struct {
+	Base         string   `json:"base" default:"origin/main" description:"Base branch the changes are compared to."`
+	Staged       *bool    `json:"staged" default:"true" description:"Include the staged changes."`
+	Unstaged     *bool    `json:"unstaged" default:"true" description:"Include the unstaged changes."`
+	Untracked    *bool    `json:"untracked" default:"true" description:"Include the untracked files."`
+	Include      []string `json:"include" description:"Pathspecs of the files to include."`
+	Exclude      []string `json:"exclude" description:"Pathspecs of the files to exclude."`
+	ContextLines *int     `json:"contextLines" default:"3" description:"Number of context lines around the changes."`
+}
+
+// GitInfoSpecOutputChangedFiles are the options of the list of the files changed from the merge-base of a base ref
+type GitInfoSpecOutputChangedFiles struct {
+	Base          string   `json:"base" default:"origin/main" description:"Base ref the changes are compared to."`
+	LocalChanges  *bool    `json:"localChanges" default:"true" description:"Include the staged, unstaged and untracked changes."`
+	RollupMarkers []string `json:"rollupMarkers" description:"Names of the files marking the root of a package, to roll up the changes by package."`
+	RollupDepth   int      `json:"rollupDepth" description:"Number of directory levels to roll up the changes by directory, when there are no markers."`
+}
+
+// GitInfoSpecOutputVersion are the options of the semantic version computed from the git tags
+type GitInfoSpecOutputVersion struct {
+	TagPrefix  string   `json:"tagPrefix" description:"Prefix of the tags, such as api/ for the tags of a monorepo module."`
+	Prerelease *string  `json:"prerelease" default:"dev" description:"Identifier of the pre-release versions - only the commit count when empty."`
+	PatchTypes []string `json:"patchTypes" description:"Types of the conventional commits bumping the patch version, such as fix and perf - all the commits when empty."`
+}
+
+// GitInfoSpecOutputChangelog are the options of the Markdown changelog between two refs
+type GitInfoSpecOutputChangelog struct {
+	From      string `json:"from" description:"Start of the range, excluded - default to the latest semver tag before the end of the range."`
+	To        string `json:"to" default:"HEAD" description:"End of the range, included."`
+	TagPrefix string `json:"tagPrefix" description:"Prefix of the tags, to find the default start of the range."`
+	Title     string `json:"title" description:"Title of the changelog - default to the end of the range, or Unreleased for HEAD."`
+	RepoURL   string `json:"repoURL" description:"URL of the repository, for the links to the commits and pull requests - default to the origin remote."`
+}
+
+// GitInfoSpecOutputOwnership are the options of the owners and last authors of the files changed from the merge-base of a base ref
+type GitInfoSpecOutputOwnership struct {
+	Base           string `json:"base" default:"origin/main" description:"Base ref the changes are compared to."`
+	LocalChanges   *bool  `json:"localChanges" default:"true" description:"Include the staged, unstaged and untracked changes."`
+	CodeownersFile string `json:"codeownersFile" description:"Path on the host of the CODEOWNERS file - default to the one of the repository."`
+	Format         string `json:"format" default:"json" enum:"json,markdown" description:"Format of the output: json, or a markdown table."`
+}
+
+// GitInfoSpecOutputArchive are the options of the reproducible source archive of a ref
+type GitInfoSpecOutputArchive struct {
+	Ref    string `json:"ref" default:"HEAD" description:"Ref of the sources, such as a tag."`
+	Format string `json:"format" default:"tar.gz" enum:"tar.gz,zip" description:"Format of the archive: tar.gz or zip."`
+	Prefix string `json:"prefix" description:"Directory prefixed to the paths of the archive, such as myproject-1.0.0."`
+}
+
+func (s GitInfoSpec) Validate() error {
+	var errs []error
+	for i, output := range s.Outputs {
+		if output.Type == "raw" && len(output.RawCmd) == 0 {
+			errs = append(errs, brickspec.Errorf(fmt.Sprintf("outputs[%d].rawCmd", i), "is required for the raw type"))
+		}
+		if output.ChangedFiles.RollupDepth < 0 {
+			errs = append(errs, brickspec.Errorf(fmt.Sprintf("outputs[%d].changedFiles.rollupDepth", i), "must not be negative"))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func (s GitInfoSpec) Plan(brick mason.Brick) map[string]string {
@@ -28,30 +101,118 @@ func (s GitInfoSpec) Plan(brick mason.Brick) map[string]string {
 	return plan
 }
 
+func (s GitInfoSpec) Artifacts() brickspec.Artifacts {
+	artifacts := brickspec.Artifacts{
+		Inputs: []string{s.GitDirectory},
+	}
+	for _, output := range s.Outputs {
+		if output.Type == "ownership" && output.Ownership.CodeownersFile != "" {
+			artifacts.Inputs = append(artifacts.Inputs, output.Ownership.CodeownersFile)
+		}
+		artifacts.Outputs = append(artifacts.Outputs, output.HostFilePath)
+		artifacts.Produces = append(artifacts.Produces, output.DaggerFileName)
+	}
+	return artifacts
+}
+
 func (s GitInfoSpec) script(brick mason.Brick) string {
-	gitDirectory := "host | directory "
-	if s.GitDirectory != "" {
-		gitDirectory += s.GitDirectory
-	} else {
-		gitDirectory += "."
+	baseCmd := func() *brickspec.Cmd {
+		return brickspec.GitInfoCommand(brick.ModuleRef, s.GitDirectory, s.FetchMissingRefs, s.CIEnv)
 	}
-	baseCmd := brick.ModuleRef + " --git-directory $(" + gitDirectory + ")"
 
-	var cmd string
+	var script brickspec.Script
 	for _, output := range s.Outputs {
 		switch output.Type {
 		case "diff":
-			cmd += fmt.Sprintf("%s=$(%s | diff-file)\n", output.DaggerFileName, baseCmd)
+			script.Assign(output.DaggerFileName, baseCmd().Pipe("diff-file"))
+		case "mergeBaseDiff":
+			script.Assign(output.DaggerFileName, output.Diff.cmd(baseCmd()))
+		case "changedFiles":
+			script.Assign(output.DaggerFileName, output.ChangedFiles.cmd(baseCmd()))
+		case "version":
+			script.Assign(output.DaggerFileName, output.Version.cmd(baseCmd()))
+		case "changelog":
+			script.Assign(output.DaggerFileName, output.Changelog.cmd(baseCmd()))
+		case "ownership":
+			script.Assign(output.DaggerFileName, output.Ownership.cmd(baseCmd()))
+		case "archive":
+			script.Assign(output.DaggerFileName, output.Archive.cmd(baseCmd()))
 		case "info":
-			cmd += fmt.Sprintf("%s=$(%s | info-file)\n", output.DaggerFileName, baseCmd)
+			script.Assign(output.DaggerFileName, baseCmd().Pipe("info-file"))
 		default:
-			cmd += fmt.Sprintf("%s=$(%s | raw-cmd-as-file \"%s\")\n", output.DaggerFileName, baseCmd, strings.Join(output.RawCmd, `","`))
+			script.Assign(output.DaggerFileName, baseCmd().Pipe("raw-cmd-as-file").ListArg(output.RawCmd))
 		}
 
 		if output.HostFilePath != "" {
-			cmd += fmt.Sprintf("$%s | export %s\n.echo\n", output.DaggerFileName, output.HostFilePath)
+			script.Run(brickspec.FromRef(brickspec.Var(output.DaggerFileName)).Pipe("export", output.HostFilePath))
+			script.Echo("")
 		}
 	}
 
+	return script.String()
+}
+
+func (d GitInfoSpecOutputDiff) cmd(baseCmd *brickspec.Cmd) *brickspec.Cmd {
+	cmd := baseCmd.Pipe("merge-base-diff-file").
+		Flag("base", d.Base).
+		DisableFlag("staged", enabled(d.Staged)).
+		DisableFlag("unstaged", enabled(d.Unstaged)).
+		DisableFlag("untracked", enabled(d.Untracked)).
+		ListFlag("include", d.Include).
+		ListFlag("exclude", d.Exclude)
+	if d.ContextLines != nil {
+		cmd.Flag("context-lines", strconv.Itoa(*d.ContextLines))
+	}
 	return cmd
 }
+
+func (c GitInfoSpecOutputChangedFiles) cmd(baseCmd *brickspec.Cmd) *brickspec.Cmd {
+	return baseCmd.Pipe("changed-files").
+		Flag("base", c.Base).
+		DisableFlag("local-changes", enabled(c.LocalChanges)).
+		ListFlag("rollup-markers", c.RollupMarkers).
+		IntFlag("rollup-depth", c.RollupDepth)
+}
+
+func (v GitInfoSpecOutputVersion) cmd(baseCmd *brickspec.Cmd) *brickspec.Cmd {
+	cmd := baseCmd.Pipe("version-file").
+		Flag("tag-prefix", v.TagPrefix)
+	if v.Prerelease != nil {
+		cmd.FlagAllowEmpty("prerelease", *v.Prerelease)
+	}
+	return cmd.ListFlag("patch-types", v.PatchTypes)
+}
+
+func (c GitInfoSpecOutputChangelog) cmd(baseCmd *brickspec.Cmd) *brickspec.Cmd {
+	return baseCmd.Pipe("changelog").
+		Flag("from", c.From).
+		Flag("to", c.To).
+		Flag("tag-prefix", c.TagPrefix).
+		Flag("title", c.Title).
+		Flag("repo-url", c.RepoURL)
+}
+
+func (o GitInfoSpecOutputOwnership) cmd(baseCmd *brickspec.Cmd) *brickspec.Cmd {
+	cmd := baseCmd.Pipe("ownership").
+		Flag("base", o.Base).
+		DisableFlag("local-changes", enabled(o.LocalChanges))
+	if o.CodeownersFile != "" {
+		cmd.RefFlag("codeowners-file", brickspec.Sub(brickspec.HostFile(o.CodeownersFile)))
+	}
+	if o.Format == "markdown" {
+		return cmd.Pipe("markdown-file")
+	}
+	return cmd.Pipe("json-file")
+}
+
+func (a GitInfoSpecOutputArchive) cmd(baseCmd *brickspec.Cmd) *brickspec.Cmd {
+	return baseCmd.Pipe("archive").
+		Flag("ref", a.Ref).
+		Flag("format", a.Format).
+		Flag("prefix", a.Prefix)
+}
+
+// enabled returns the value of an option enabled by default
+func enabled(value *bool) bool {
+	return value == nil || *value
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/vbehar/mason-modules/brickspec"
+	"github.com/vbehar/mason-sdk-go"
+)
+
+type SecretScanSpec struct {
+	GitDirectory     string               `json:"gitDirectory" default:"." description:"Path of the git repository on the host."`
+	Targets          []string             `json:"targets" description:"Targets of the diff to scan - default to origin."`
+	RevisionRange    string               `json:"revisionRange" description:"Range of the commits to scan, such as origin/main..HEAD, instead of the diff."`
+	RulesFile        string               `json:"rulesFile" description:"Path on the host of a JSON file with additional rules."`
+	AllowPaths       []string             `json:"allowPaths" description:"Glob patterns of the paths to ignore."`
+	AllowPatterns    []string             `json:"allowPatterns" description:"Regular expressions of the secrets to ignore."`
+	IgnoreMarker     string               `json:"ignoreMarker" description:"Marker ignoring the secrets of a line - default to secretscan:allow."`
+	FetchMissingRefs bool                 `json:"fetchMissingRefs" description:"Fetch the missing refs - and deepen the shallow clones - when a remote is reachable, such as in the CI checkouts."`
+	CIEnv            []string             `json:"ciEnv" description:"Environment variables of the CI, as KEY=VALUE, to find the branch name of a detached HEAD - default to the branch variables of the common CI systems, from the host."`
+	Output           SecretScanSpecOutput `json:"output" description:"Where to write the findings."`
+}
+
+type SecretScanSpecOutput struct {
+	JSONDaggerFileName  string `json:"jsonDaggerFileName" validate:"identifier" description:"Name of the dagger variable holding the JSON findings, for use by other bricks."`
+	JSONHostFilePath    string `json:"jsonHostFilePath" description:"Path on the host where to write the JSON findings."`
+	SarifDaggerFileName string `json:"sarifDaggerFileName" validate:"identifier" description:"Name of the dagger variable holding the SARIF findings, for use by other bricks."`
+	SarifHostFilePath   string `json:"sarifHostFilePath" description:"Path on the host where to write the SARIF findings."`
+}
+
+func (s SecretScanSpec) Validate() error {
+	var errs []error
+	if s.RevisionRange != "" && len(s.Targets) > 0 {
+		errs = append(errs, brickspec.Errorf("targets", "can't be set with a revision range"))
+	}
+	for i, pattern := range s.AllowPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, brickspec.Errorf(fmt.Sprintf("allowPatterns[%d]", i), "invalid regular expression: %v", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s SecretScanSpec) Plan(brick mason.Brick) map[string]string {
+	plan := map[string]string{
+		"lint_" + brick.Filename(): s.scanScript(brick),
+	}
+	for _, phase := range brick.Metadata.ExtraPhases {
+		plan[phase+"_"+brick.Filename()] = plan["lint_"+brick.Filename()]
+	}
+	return plan
+}
+
+func (s SecretScanSpec) Artifacts() brickspec.Artifacts {
+	return brickspec.Artifacts{
+		Inputs:   []string{s.GitDirectory, s.RulesFile},
+		Outputs:  []string{s.Output.JSONHostFilePath, s.Output.SarifHostFilePath},
+		Produces: []string{s.Output.JSONDaggerFileName, s.Output.SarifDaggerFileName},
+	}
+}
+
+func (s SecretScanSpec) scanScript(brick mason.Brick) string {
+	baseCmd := func() *brickspec.Cmd {
+		cmd := brickspec.GitInfoCommand(brick.ModuleRef, s.GitDirectory, s.FetchMissingRefs, s.CIEnv).
+			Pipe("scan-secrets").
+			ListFlag("targets", s.Targets).
+			Flag("revision-range", s.RevisionRange)
+		if s.RulesFile != "" {
+			cmd.RefFlag("rules-file", brickspec.Sub(brickspec.HostFile(s.RulesFile)))
+		}
+		return cmd.
+			ListFlag("allow-paths", s.AllowPaths).
+			ListFlag("allow-patterns", s.AllowPatterns).
+			Flag("ignore-marker", s.IgnoreMarker)
+	}
+
+	var script brickspec.Script
+	for _, output := range []struct {
+		fn, daggerFileName, hostFilePath string
+	}{
+		{"json-file", s.Output.JSONDaggerFileName, s.Output.JSONHostFilePath},
+		{"sarif-file", s.Output.SarifDaggerFileName, s.Output.SarifHostFilePath},
+	} {
+		switch {
+		case output.daggerFileName != "":
+			script.Assign(output.daggerFileName, baseCmd().Pipe(output.fn))
+			if output.hostFilePath != "" {
+				script.Run(brickspec.FromRef(brickspec.Var(output.daggerFileName)).Pipe("export", output.hostFilePath))
+			}
+		case output.hostFilePath != "":
+			script.Run(baseCmd().Pipe(output.fn).Pipe("export", output.hostFilePath))
+		}
+	}
+	script.Echo("")
+	script.Run(baseCmd().Pipe("assert"))
+
+	return script.String()
+}
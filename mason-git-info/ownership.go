@@ -0,0 +1,320 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"dagger/mason-git-info/codeowners"
+	"dagger/mason-git-info/internal/dagger"
+)
+
+// ownershipReport is the ownership of the files changed since the merge-base of a base ref
+type ownershipReport struct {
+	Base      string `json:"base"`
+	MergeBase string `json:"mergeBase"`
+	// the path of the CODEOWNERS file - empty if there is none
+	Codeowners string          `json:"codeowners,omitempty"`
+	Files      []fileOwnership `json:"files"`
+	// the changed files of each owner, for the review routing
+	Owners []ownerFiles `json:"owners"`
+}
+
+// fileOwnership are the owners of a changed file, and the last authors of its changed lines
+type fileOwnership struct {
+	Path    string `json:"path"`
+	Status  string `json:"status"`
+	OldPath string `json:"oldPath,omitempty"`
+	// the owners from the CODEOWNERS file
+	Owners []string `json:"owners"`
+	// the authors of the changed lines at the merge-base, by number of lines
+	LastAuthors []lineAuthor `json:"lastAuthors"`
+}
+
+// lineAuthor is the author of some of the changed lines
+type lineAuthor struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Lines int    `json:"lines"`
+	// the date of the latest change of these lines
+	LastChange string `json:"lastChange"`
+}
+
+type ownerFiles struct {
+	Owner string   `json:"owner"`
+	Files []string `json:"files"`
+}
+
+// Ownership returns who owns the code changed since the merge-base of HEAD and the base ref:
+// the owners of each changed file from the CODEOWNERS file, in the GitHub or GitLab syntax,
+// and the last authors of its changed lines, from git blame.
+func (g *MasonGitInfo) Ownership(
+	ctx context.Context,
+	// base ref the changes are compared to
+	// +optional
+	// +default="origin/main"
+	base string,
+	// include the local changes: staged, unstaged and untracked
+	// +optional
+	// +default=true
+	localChanges bool,
+	// CODEOWNERS file - default to the one of the repository, in .github/, .gitlab/, docs/ or at the root
+	// +optional
+	codeownersFile *dagger.File,
+) (*OwnershipReport, error) {
+	changes, err := g.changeList(ctx, base, localChanges, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	report := &ownershipReport{
+		Base:      changes.Base,
+		MergeBase: changes.MergeBase,
+		Files:     []fileOwnership{},
+		Owners:    []ownerFiles{},
+	}
+
+	var content string
+	if codeownersFile != nil {
+		if report.Codeowners, err = codeownersFile.Name(ctx); err != nil {
+			return nil, err
+		}
+		if content, err = codeownersFile.Contents(ctx); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, location := range codeowners.Locations {
+			// the missing files are expected
+			if content, err = g.git(ctx, "show", "HEAD:"+location); err == nil {
+				report.Codeowners = location
+				break
+			}
+		}
+	}
+	owners, err := codeowners.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []string{changes.MergeBase}
+	if !localChanges {
+		revisions = append(revisions, "HEAD")
+	}
+	diff, err := g.git(ctx, slices.Concat([]string{
+		"-c", "core.quotePath=false", "diff", "--find-renames", "--unified=0", "--no-color",
+	}, revisions)...)
+	if err != nil {
+		return nil, err
+	}
+	hunks := parseOldHunks(diff)
+
+	ownerIndex := make(map[string]int)
+	for _, file := range changes.Files {
+		ownership := fileOwnership{
+			Path:        file.Path,
+			Status:      file.Status,
+			OldPath:     file.OldPath,
+			Owners:      owners.Owners(file.Path),
+			LastAuthors: []lineAuthor{},
+		}
+		if ownership.Owners == nil {
+			ownership.Owners = []string{}
+		}
+		if ranges := hunks[file.Path]; len(ranges) > 0 && file.Status != "added" && !file.Binary {
+			oldPath := cmp.Or(file.OldPath, file.Path)
+			if ownership.LastAuthors, err = g.blameAuthors(ctx, changes.MergeBase, oldPath, ranges); err != nil {
+				return nil, err
+			}
+		}
+		for _, owner := range ownership.Owners {
+			i, found := ownerIndex[owner]
+			if !found {
+				i = len(report.Owners)
+				ownerIndex[owner] = i
+				report.Owners = append(report.Owners, ownerFiles{Owner: owner})
+			}
+			report.Owners[i].Files = append(report.Owners[i].Files, file.Path)
+		}
+		report.Files = append(report.Files, ownership)
+	}
+	slices.SortStableFunc(report.Owners, func(a, b ownerFiles) int {
+		return cmp.Or(cmp.Compare(len(b.Files), len(a.Files)), strings.Compare(a.Owner, b.Owner))
+	})
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &OwnershipReport{Report: string(data)}, nil
+}
+
+// OwnershipReport is the ownership of the changed files
+type OwnershipReport struct {
+	// the JSON report
+	Report string
+}
+
+// JSONFile returns the report as a JSON file
+func (o *OwnershipReport) JSONFile() *dagger.File {
+	return dag.File("ownership.json", o.Report)
+}
+
+// MarkdownFile returns the report as a Markdown table, for a pull request comment or a code review
+func (o *OwnershipReport) MarkdownFile() (*dagger.File, error) {
+	var report ownershipReport
+	if err := json.Unmarshal([]byte(o.Report), &report); err != nil {
+		return nil, err
+	}
+	return dag.File("ownership.md", renderOwnership(report)), nil
+}
+
+// blameAuthors returns the authors of the given line ranges of the file at the given revision
+func (g *MasonGitInfo) blameAuthors(ctx context.Context, revision, file string, ranges []lineRange) ([]lineAuthor, error) {
+	args := []string{"blame", "--line-porcelain"}
+	for _, r := range ranges {
+		args = append(args, "-L", fmt.Sprintf("%d,+%d", r.start, r.count))
+	}
+	blame, err := g.git(ctx, append(args, revision, "--", file)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlameAuthors(blame), nil
+}
+
+// lineRange is a range of lines of a file
+type lineRange struct {
+	start, count int
+}
+
+var oldHunkRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+\d+(?:,\d+)? @@`)
+
+// parseOldHunks returns the line ranges of the old files changed by the given unified diff, by path -
+// the new one, except for the deleted files.
+// A pure addition is attributed to the line before it, as the closest context.
+func parseOldHunks(diff string) map[string][]lineRange {
+	hunks := make(map[string][]lineRange)
+	var file string
+	inHunk := false
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			inHunk = false
+			file = ""
+		case strings.HasPrefix(line, "--- ") && !inHunk && file == "":
+			// the old path, kept for the deleted files
+			file = strings.TrimPrefix(strings.TrimPrefix(line, "--- "), "a/")
+			if file == "/dev/null" {
+				file = ""
+			}
+		case strings.HasPrefix(line, "+++ ") && !inHunk:
+			if newFile := strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/"); newFile != "/dev/null" {
+				file = newFile
+			}
+		case file != "" && strings.HasPrefix(line, "@@ "):
+			inHunk = true
+			match := oldHunkRe.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			start, _ := strconv.Atoi(match[1])
+			count := 1
+			if match[2] != "" {
+				count, _ = strconv.Atoi(match[2])
+			}
+			if count == 0 {
+				if start == 0 {
+					// an addition at the beginning of the file: no line before it
+					continue
+				}
+				count = 1
+			}
+			hunks[file] = append(hunks[file], lineRange{start: start, count: count})
+		}
+	}
+	return hunks
+}
+
+// parseBlameAuthors aggregates the output of git blame --line-porcelain by author
+func parseBlameAuthors(blame string) []lineAuthor {
+	var (
+		authors []lineAuthor
+		name    string
+		email   string
+	)
+	for _, line := range strings.Split(blame, "\n") {
+		switch {
+		case strings.HasPrefix(line, "author "):
+			name = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-mail "):
+			email = strings.Trim(strings.TrimPrefix(line, "author-mail "), "<>")
+		case strings.HasPrefix(line, "author-time "):
+			timestamp, _ := strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64)
+			date := time.Unix(timestamp, 0).UTC().Format(time.DateOnly)
+			i := slices.IndexFunc(authors, func(a lineAuthor) bool { return a.Name == name && a.Email == email })
+			if i < 0 {
+				i = len(authors)
+				authors = append(authors, lineAuthor{Name: name, Email: email})
+			}
+			authors[i].Lines++
+			authors[i].LastChange = max(authors[i].LastChange, date)
+		}
+	}
+	slices.SortStableFunc(authors, func(a, b lineAuthor) int {
+		return cmp.Or(cmp.Compare(b.Lines, a.Lines), strings.Compare(b.LastChange, a.LastChange))
+	})
+	return authors
+}
+
+// renderOwnership renders the report as Markdown
+func renderOwnership(report ownershipReport) string {
+	var md strings.Builder
+	fmt.Fprintf(&md, "## Ownership of the changes\n\nChanges since `%s` (merge-base `%s`)", report.Base, report.MergeBase[:min(len(report.MergeBase), 7)])
+	if report.Codeowners != "" {
+		fmt.Fprintf(&md, ", with the owners from `%s`", report.Codeowners)
+	}
+	md.WriteString(".\n\n")
+	if len(report.Files) == 0 {
+		md.WriteString("No changes.\n")
+		return md.String()
+	}
+
+	md.WriteString("| File | Owners | Last authors |\n")
+	md.WriteString("| --- | --- | --- |\n")
+	for _, file := range report.Files {
+		path := "`" + file.Path + "`"
+		if file.OldPath != "" {
+			path = "`" + file.OldPath + "` → " + path
+		}
+		authors := make([]string, 0, len(file.LastAuthors))
+		for _, author := range file.LastAuthors {
+			lines := "lines"
+			if author.Lines == 1 {
+				lines = "line"
+			}
+			authors = append(authors, fmt.Sprintf("%s (%d %s, %s)", author.Name, author.Lines, lines, author.LastChange))
+		}
+		fmt.Fprintf(&md, "| %s | %s | %s |\n",
+			markdownCell(path+" ("+file.Status+")"),
+			markdownCell(cmp.Or(strings.Join(file.Owners, ", "), "_none_")),
+			markdownCell(cmp.Or(strings.Join(authors, ", "), "-")),
+		)
+	}
+
+	if len(report.Owners) > 0 {
+		md.WriteString("\n### Reviewers\n\n")
+		for _, owner := range report.Owners {
+			fmt.Fprintf(&md, "- %s: %d files\n", owner.Owner, len(owner.Files))
+		}
+	}
+	return md.String()
+}
+
+// markdownCell escapes the pipes of a Markdown table cell
+func markdownCell(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
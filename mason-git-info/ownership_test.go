@@ -0,0 +1,113 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOldHunks(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+index 83db48f..bf269f4 100644
+--- a/main.go
++++ b/main.go
+@@ -3,2 +3,2 @@ package main
+-// old
+--- removed line, like an old path
++// new
+++++ added line, like a new path
+@@ -10,0 +11,3 @@ func main() {
++	added()
+@@ -20 +24 @@ func main() {
+-	old()
++	new()
+diff --git a/old.go b/renamed.go
+similarity index 90%
+rename from old.go
+rename to renamed.go
+--- a/old.go
++++ b/renamed.go
+@@ -0,0 +1 @@
++// added at the beginning
+@@ -5,3 +6,0 @@
+-removed
+diff --git a/deleted.go b/deleted.go
+deleted file mode 100644
+--- a/deleted.go
++++ /dev/null
+@@ -1,4 +0,0 @@
+-package main
+diff --git a/new.go b/new.go
+new file mode 100644
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,2 @@
++package main
+diff --git a/logo.png b/logo.png
+Binary files a/logo.png and b/logo.png differ
+`
+	expected := map[string][]lineRange{
+		"main.go":    {{start: 3, count: 2}, {start: 10, count: 1}, {start: 20, count: 1}},
+		"renamed.go": {{start: 5, count: 3}},
+		"deleted.go": {{start: 1, count: 4}},
+	}
+	if actual := parseOldHunks(diff); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected hunks:\n%+v\nexpected:\n%+v", actual, expected)
+	}
+}
+
+func TestParseBlameAuthors(t *testing.T) {
+	// as written by git blame --line-porcelain, with the times of 2024-01-01, 2024-02-01 and 2024-03-01
+	blame := `1111111111111111111111111111111111111111 3 3 2
+author Alice
+author-mail <alice@example.com>
+author-time 1704067200
+author-tz +0000
+summary first
+filename main.go
+	line 3
+1111111111111111111111111111111111111111 4 4
+author Alice
+author-mail <alice@example.com>
+author-time 1704067200
+author-tz +0000
+summary first
+filename main.go
+	author-time 0
+2222222222222222222222222222222222222222 5 5 1
+author Bob
+author-mail <bob@example.com>
+author-time 1709251200
+author-tz +0000
+summary second
+filename main.go
+	line 5
+3333333333333333333333333333333333333333 6 6 1
+author Alice
+author-mail <alice@example.com>
+author-time 1706745600
+author-tz +0000
+summary third
+filename main.go
+	line 6
+4444444444444444444444444444444444444444 7 7 1
+author Alice
+author-mail <alice@other.example.com>
+author-time 1709251200
+author-tz +0000
+summary fourth
+filename main.go
+	line 7
+`
+	expected := []lineAuthor{
+		{Name: "Alice", Email: "alice@example.com", Lines: 3, LastChange: "2024-02-01"},
+		{Name: "Bob", Email: "bob@example.com", Lines: 1, LastChange: "2024-03-01"},
+		{Name: "Alice", Email: "alice@other.example.com", Lines: 1, LastChange: "2024-03-01"},
+	}
+	if actual := parseBlameAuthors(blame); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected authors:\n%+v\nexpected:\n%+v", actual, expected)
+	}
+
+	if actual := parseBlameAuthors(""); len(actual) != 0 {
+		t.Errorf("expected no authors, got %+v", actual)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"dagger/mason-git-info/internal/dagger"
+)
+
+// secretRule is a rule detecting a kind of secret
+type secretRule struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	// regular expression matching the secret - or its first group, if any
+	Regex string `json:"regex"`
+	// minimum Shannon entropy of the secret, in bits per character, to filter out the placeholders
+	MinEntropy float64 `json:"minEntropy"`
+	re         *regexp.Regexp
+}
+
+// defaultSecretRules are the rules of the well-known secrets
+var defaultSecretRules = []secretRule{
+	{ID: "private-key", Description: "Private key", Regex: `-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----`},
+	{ID: "aws-access-key-id", Description: "AWS access key ID", Regex: `\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`},
+	{ID: "aws-secret-access-key", Description: "AWS secret access key", Regex: `(?i)aws.{0,20}?(?:secret|key).{0,20}?['"]([0-9a-zA-Z/+]{40})['"]`, MinEntropy: 3.5},
+	{ID: "github-token", Description: "GitHub token", Regex: `\b((?:gh[pousr]_[0-9A-Za-z]{36,255})|(?:github_pat_[0-9A-Za-z_]{82}))\b`},
+	{ID: "gitlab-token", Description: "GitLab personal access token", Regex: `\b(glpat-[0-9A-Za-z_-]{20})\b`},
+	{ID: "slack-token", Description: "Slack token", Regex: `\b(xox[abposr]-[0-9A-Za-z-]{10,})\b`},
+	{ID: "stripe-key", Description: "Stripe live key", Regex: `\b((?:sk|rk)_live_[0-9A-Za-z]{24,})\b`},
+	{ID: "google-api-key", Description: "Google API key", Regex: `\b(AIza[0-9A-Za-z_-]{35})\b`},
+	{ID: "jwt", Description: "JSON Web Token", Regex: `\b(eyJ[0-9A-Za-z_-]{10,}\.eyJ[0-9A-Za-z_-]{10,}\.[0-9A-Za-z_-]{10,})\b`, MinEntropy: 3},
+	{ID: "generic-secret", Description: "Generic secret assignment", Regex: `(?i)(?:secret|token|passw(?:or)?d|api[_-]?key)['"]?\s*[:=]\s*['"]([^'"\s]{16,})['"]`, MinEntropy: 3.5},
+}
+
+// secretFinding is a secret found in an added line
+type secretFinding struct {
+	RuleID      string `json:"ruleId"`
+	Description string `json:"description"`
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	// the commit adding the line, when scanning the history
+	Commit string `json:"commit,omitempty"`
+	// the secret, redacted
+	Secret  string  `json:"secret"`
+	Entropy float64 `json:"entropy"`
+}
+
+// diffLine is a line added by a diff
+type diffLine struct {
+	Commit string
+	File   string
+	Line   int
+	Text   string
+}
+
+// ScanSecrets looks for leaked credentials in the lines added by the diff returned by DiffFile,
+// or - if a revision range is set - by every commit of the range.
+// The built-in rules detect well-known tokens and keys, with an entropy check to ignore the placeholders.
+// A line is ignored if it contains the ignore marker, usually in a comment.
+// Use the Assert function of the result to fail on findings.
+func (g *MasonGitInfo) ScanSecrets(
+	ctx context.Context,
+	// targets of the diff, when scanning the diff
+	// +optional
+	// +default=["origin"]
+	targets []string,
+	// range of the commits to scan, such as "origin/main..HEAD", instead of the diff
+	// +optional
+	revisionRange string,
+	// JSON file with additional rules: an array of objects with an id, description, regex and minEntropy
+	// +optional
+	rulesFile *dagger.File,
+	// glob patterns of the paths to ignore - with ** for any number of directories
+	// +optional
+	allowPaths []string,
+	// regular expressions of the secrets to ignore, such as test fixtures
+	// +optional
+	allowPatterns []string,
+	// marker ignoring the secrets of a line
+	// +optional
+	// +default="secretscan:allow"
+	ignoreMarker string,
+) (*SecretScanRun, error) {
+	rules := slices.Clone(defaultSecretRules)
+	if rulesFile != nil {
+		data, err := rulesFile.Contents(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rules file: %w", err)
+		}
+		var extraRules []secretRule
+		if err := json.Unmarshal([]byte(data), &extraRules); err != nil {
+			return nil, fmt.Errorf("invalid rules file: %w", err)
+		}
+		rules = append(rules, extraRules...)
+	}
+	for i := range rules {
+		var err error
+		if rules[i].re, err = regexp.Compile(rules[i].Regex); err != nil {
+			return nil, fmt.Errorf("invalid regex of rule %q: %w", rules[i].ID, err)
+		}
+	}
+	allowRes := make([]*regexp.Regexp, 0, len(allowPatterns))
+	for _, pattern := range allowPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allow pattern %q: %w", pattern, err)
+		}
+		allowRes = append(allowRes, re)
+	}
+
+	var diff string
+	var err error
+	if revisionRange != "" {
+		diff, err = g.Container.WithExec([]string{
+			"git", "log", "-p", "-U0", "--no-color", "--no-merges", "--format=commit %H", revisionRange, "--",
+		}).Stdout(ctx)
+	} else {
+		var diffFile *dagger.File
+		if diffFile, err = g.DiffFile(ctx, targets); err == nil {
+			diff, err = diffFile.Contents(ctx)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(scanDiff(diff, rules, allowPaths, allowRes, ignoreMarker), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &SecretScanRun{Findings: string(data)}, nil
+}
+
+// SecretScanRun is the result of the secret scanning
+type SecretScanRun struct {
+	// the JSON findings
+	Findings string
+}
+
+// Assert fails if secrets were found, and returns a summary of the scan
+func (s *SecretScanRun) Assert() (string, error) {
+	findings, err := s.findings()
+	if err != nil {
+		return "", err
+	}
+	if len(findings) == 0 {
+		return "no secrets found", nil
+	}
+	lines := make([]string, 0, len(findings))
+	for _, f := range findings {
+		location := f.File + ":" + strconv.Itoa(f.Line)
+		if f.Commit != "" {
+			location = f.Commit[:min(len(f.Commit), 7)] + " " + location
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s (%s): %s", location, f.Description, f.RuleID, f.Secret))
+	}
+	summary := fmt.Sprintf("%d secrets found:\n%s", len(findings), strings.Join(lines, "\n"))
+	return summary, fmt.Errorf("secret scan failed: %s", summary)
+}
+
+// JSONFile returns the findings as a JSON file
+func (s *SecretScanRun) JSONFile() *dagger.File {
+	return dag.File("secrets.json", s.Findings)
+}
+
+// SarifFile returns the findings as a SARIF file, for the code scanning tools
+func (s *SecretScanRun) SarifFile() (*dagger.File, error) {
+	findings, err := s.findings()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(sarifReport(findings), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return dag.File("secrets.sarif", string(data)), nil
+}
+
+func (s *SecretScanRun) findings() ([]secretFinding, error) {
+	var findings []secretFinding
+	if err := json.Unmarshal([]byte(s.Findings), &findings); err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
+
+// scanDiff returns the secrets found in the lines added by the given diff,
+// except in the allowed paths and in the lines with the ignore marker
+func scanDiff(diff string, rules []secretRule, allowPaths []string, allowRes []*regexp.Regexp, ignoreMarker string) []secretFinding {
+	findings := []secretFinding{}
+	for _, line := range parseAddedLines(diff) {
+		if matchAny(allowPaths, line.File, matchPath) || (ignoreMarker != "" && strings.Contains(line.Text, ignoreMarker)) {
+			continue
+		}
+		findings = append(findings, scanLine(line, rules, allowRes)...)
+	}
+	return findings
+}
+
+// parseAddedLines returns the lines added by the given unified diff,
+// optionally split by "commit <sha>" lines, as written by git log -p
+func parseAddedLines(diff string) []diffLine {
+	var lines []diffLine
+	var current diffLine
+	inHunk := false
+	for _, text := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(text, "commit ") && !inHunk:
+			current.Commit = strings.TrimPrefix(text, "commit ")
+		case strings.HasPrefix(text, "diff "):
+			inHunk = false
+			current.File = ""
+		case strings.HasPrefix(text, "+++ ") && !inHunk:
+			current.File = strings.TrimPrefix(strings.TrimPrefix(text, "+++ "), "b/")
+			if current.File == "/dev/null" {
+				current.File = ""
+			}
+		case strings.HasPrefix(text, "@@ "):
+			inHunk = current.File != ""
+			current.Line = hunkStart(text)
+		case !inHunk:
+		case strings.HasPrefix(text, "+"):
+			line := current
+			line.Text = text[1:]
+			lines = append(lines, line)
+			current.Line++
+		case strings.HasPrefix(text, " "):
+			current.Line++
+		case strings.HasPrefix(text, "-"), strings.HasPrefix(text, `\`):
+		default:
+			// end of the hunks of the file, such as the next commit
+			inHunk = false
+			if strings.HasPrefix(text, "commit ") {
+				current.Commit = strings.TrimPrefix(text, "commit ")
+			}
+		}
+	}
+	return lines
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// hunkStart returns the first line of the new file in the given hunk header
+func hunkStart(header string) int {
+	match := hunkHeaderRe.FindStringSubmatch(header)
+	if match == nil {
+		return 0
+	}
+	start, _ := strconv.Atoi(match[1])
+	return start
+}
+
+// scanLine returns the secrets found in the given line
+func scanLine(line diffLine, rules []secretRule, allowRes []*regexp.Regexp) []secretFinding {
+	var findings []secretFinding
+	for _, rule := range rules {
+		for _, match := range rule.re.FindAllStringSubmatch(line.Text, -1) {
+			secret := match[0]
+			if len(match) > 1 && match[1] != "" {
+				secret = match[1]
+			}
+			entropy := shannonEntropy(secret)
+			if entropy < rule.MinEntropy || slices.ContainsFunc(allowRes, func(re *regexp.Regexp) bool {
+				return re.MatchString(secret)
+			}) {
+				continue
+			}
+			findings = append(findings, secretFinding{
+				RuleID:      rule.ID,
+				Description: rule.Description,
+				File:        line.File,
+				Line:        line.Line,
+				Commit:      line.Commit,
+				Secret:      redact(secret),
+				Entropy:     math.Round(entropy*100) / 100,
+			})
+		}
+	}
+	return findings
+}
+
+// shannonEntropy returns the entropy of the given string, in bits per character
+func shannonEntropy(value string) float64 {
+	if value == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range value {
+		counts[r]++
+		total++
+	}
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// redact keeps only the first characters of the secret
+func redact(secret string) string {
+	runes := []rune(secret)
+	return string(runes[:min(len(runes)/4, 6)]) + "***"
+}
+
+// sarifReport returns the SARIF 2.1.0 report of the findings
+func sarifReport(findings []secretFinding) map[string]any {
+	rules := []map[string]any{}
+	results := []map[string]any{}
+	for _, f := range findings {
+		if !slices.ContainsFunc(rules, func(rule map[string]any) bool { return rule["id"] == f.RuleID }) {
+			rules = append(rules, map[string]any{
+				"id":               f.RuleID,
+				"shortDescription": map[string]any{"text": f.Description},
+			})
+		}
+		result := map[string]any{
+			"ruleId":  f.RuleID,
+			"level":   "error",
+			"message": map[string]any{"text": fmt.Sprintf("%s found: %s", f.Description, f.Secret)},
+			"locations": []map[string]any{{
+				"physicalLocation": map[string]any{
+					"artifactLocation": map[string]any{"uri": f.File},
+					"region":           map[string]any{"startLine": f.Line},
+				},
+			}},
+		}
+		if f.Commit != "" {
+			result["properties"] = map[string]any{"commit": f.Commit}
+		}
+		results = append(results, result)
+	}
+	return map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []map[string]any{{
+			"tool": map[string]any{
+				"driver": map[string]any{
+					"name":           "mason-secretscan",
+					"informationUri": "https://github.com/vbehar/mason-modules",
+					"rules":          rules,
+				},
+			},
+			"results": results,
+		}},
+	}
+}
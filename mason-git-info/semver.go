@@ -0,0 +1,117 @@
+package main
+
+import (
+	"cmp"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// semver is a semantic version, see https://semver.org/
+type semver struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+	Build      string
+}
+
+var semverRe = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?$`)
+
+// parseSemver parses the given version, with an optional "v" prefix
+func parseSemver(version string) (semver, bool) {
+	match := semverRe.FindStringSubmatch(version)
+	if match == nil {
+		return semver{}, false
+	}
+	var v semver
+	var err error
+	for i, part := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		if *part, err = strconv.Atoi(match[i+1]); err != nil {
+			return semver{}, false
+		}
+	}
+	v.Prerelease, v.Build = match[4], match[5]
+	return v, true
+}
+
+// String returns the version, without the "v" prefix
+func (v semver) String() string {
+	version := strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor) + "." + strconv.Itoa(v.Patch)
+	if v.Prerelease != "" {
+		version += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		version += "+" + v.Build
+	}
+	return version
+}
+
+// compare returns the precedence order of the versions - the build metadata is ignored
+func (v semver) compare(other semver) int {
+	if c := cmp.Compare(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+	switch {
+	case v.Prerelease == other.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	}
+
+	ids, otherIDs := strings.Split(v.Prerelease, "."), strings.Split(other.Prerelease, ".")
+	for i := range min(len(ids), len(otherIDs)) {
+		n, err := strconv.Atoi(ids[i])
+		otherN, otherErr := strconv.Atoi(otherIDs[i])
+		var c int
+		switch {
+		case err == nil && otherErr == nil:
+			c = cmp.Compare(n, otherN)
+		case err == nil:
+			// numeric identifiers have a lower precedence
+			c = -1
+		case otherErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(ids[i], otherIDs[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(ids), len(otherIDs))
+}
+
+// bump returns the next release version for the given level: major, minor or patch.
+// The release of a pre-release version only drops its pre-release when the level doesn't raise its base,
+// such as 2.0.0 for a patch of 2.0.0-rc.1
+func (v semver) bump(level string) semver {
+	release := semver{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+	switch level {
+	case "major":
+		if v.Prerelease != "" && v.Minor == 0 && v.Patch == 0 {
+			return release
+		}
+		return semver{Major: v.Major + 1}
+	case "minor":
+		if v.Prerelease != "" && v.Patch == 0 {
+			return release
+		}
+		return semver{Major: v.Major, Minor: v.Minor + 1}
+	case "patch":
+		if v.Prerelease != "" {
+			return release
+		}
+		return semver{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	default:
+		return release
+	}
+}
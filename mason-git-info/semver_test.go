@@ -0,0 +1,145 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseSemver(t *testing.T) {
+	for _, tc := range []struct {
+		version  string
+		expected semver
+		valid    bool
+	}{
+		{version: "1.2.3", expected: semver{Major: 1, Minor: 2, Patch: 3}, valid: true},
+		{version: "v0.10.0", expected: semver{Minor: 10}, valid: true},
+		{version: "v1.0.0-rc.1", expected: semver{Major: 1, Prerelease: "rc.1"}, valid: true},
+		{version: "1.0.0+build.5", expected: semver{Major: 1, Build: "build.5"}, valid: true},
+		{version: "1.0.0-beta-2.x+sha.abc123", expected: semver{Major: 1, Prerelease: "beta-2.x", Build: "sha.abc123"}, valid: true},
+		{version: "1.2"},
+		{version: "01.2.3"},
+		{version: "1.2.3-"},
+		{version: "1.2.3-rc..1"},
+		{version: "V1.2.3"},
+		{version: "release-1.2.3"},
+	} {
+		actual, valid := parseSemver(tc.version)
+		if valid != tc.valid || actual != tc.expected {
+			t.Errorf("%s: expected %+v %t, got %+v %t", tc.version, tc.expected, tc.valid, actual, valid)
+		}
+	}
+}
+
+func TestSemverString(t *testing.T) {
+	for version, expected := range map[string]string{
+		"v1.2.3":              "1.2.3",
+		"1.0.0-rc.1":          "1.0.0-rc.1",
+		"v2.0.0-dev.3+abc123": "2.0.0-dev.3+abc123",
+	} {
+		v, _ := parseSemver(version)
+		if actual := v.String(); actual != expected {
+			t.Errorf("%s: expected %s, got %s", version, expected, actual)
+		}
+	}
+}
+
+func TestSemverCompare(t *testing.T) {
+	// ordered by precedence, from https://semver.org/#spec-item-11
+	ordered := []string{
+		"0.9.9",
+		"1.0.0-0",
+		"1.0.0-2",
+		"1.0.0-10",
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.2.0",
+		"1.10.0",
+		"2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			v, _ := parseSemver(ordered[i])
+			other, _ := parseSemver(ordered[j])
+			expected := 0
+			switch {
+			case i < j:
+				expected = -1
+			case i > j:
+				expected = 1
+			}
+			if actual := v.compare(other); actual != expected {
+				t.Errorf("%s compared to %s: expected %d, got %d", ordered[i], ordered[j], expected, actual)
+			}
+		}
+	}
+
+	v, _ := parseSemver("1.0.0+build.1")
+	other, _ := parseSemver("1.0.0+build.2")
+	if c := v.compare(other); c != 0 {
+		t.Errorf("the build metadata must be ignored, got %d", c)
+	}
+}
+
+func TestSemverBump(t *testing.T) {
+	for _, tc := range []struct {
+		version, level string
+		expected       string
+	}{
+		{version: "1.2.3+abc", level: "major", expected: "2.0.0"},
+		{version: "1.2.3+abc", level: "minor", expected: "1.3.0"},
+		{version: "1.2.3+abc", level: "patch", expected: "1.2.4"},
+		{version: "1.2.3+abc", level: "", expected: "1.2.3"},
+		{version: "1.2.3-rc.1+abc", level: "major", expected: "2.0.0"},
+		{version: "1.2.3-rc.1+abc", level: "minor", expected: "1.3.0"},
+		{version: "1.2.3-rc.1+abc", level: "patch", expected: "1.2.3"},
+		{version: "1.2.3-rc.1+abc", level: "", expected: "1.2.3"},
+		{version: "2.0.0-rc.1", level: "major", expected: "2.0.0"},
+		{version: "2.0.0-rc.1", level: "minor", expected: "2.0.0"},
+		{version: "2.0.0-rc.1", level: "patch", expected: "2.0.0"},
+		{version: "1.3.0-beta", level: "major", expected: "2.0.0"},
+		{version: "1.3.0-beta", level: "minor", expected: "1.3.0"},
+	} {
+		v, _ := parseSemver(tc.version)
+		if actual := v.bump(tc.level).String(); actual != tc.expected {
+			t.Errorf("%q bump of %s: expected %s, got %s", tc.level, tc.version, tc.expected, actual)
+		}
+	}
+}
+
+func TestVersionBump(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		current    string
+		messages   []string
+		patchTypes []string
+		expected   string
+	}{
+		{name: "no commits", current: "1.2.3"},
+		{name: "fixes", current: "1.2.3", messages: []string{"fix: a bug", "docs: the readme"}, expected: "patch"},
+		{name: "non conventional", current: "1.2.3", messages: []string{"Update the readme"}, expected: "patch"},
+		{name: "feature", current: "1.2.3", messages: []string{"fix: a bug", "feat(api): a new endpoint"}, expected: "minor"},
+		{name: "breaking", current: "1.2.3", messages: []string{"feat!: drop the v1 API", "fix: a bug"}, expected: "major"},
+		{name: "breaking footer", current: "1.2.3", messages: []string{"fix: a bug\n\nBREAKING CHANGE: the config changed"}, expected: "major"},
+		{name: "breaking 0.x", current: "0.4.1", messages: []string{"feat!: drop the v1 API"}, expected: "minor"},
+		{name: "fixes 0.x", current: "0.4.1", messages: []string{"fix: a bug"}, expected: "patch"},
+		{name: "first release", current: "0.0.0", messages: []string{"refactor!: everything"}, expected: "minor"},
+		{name: "patch types", current: "1.2.3", messages: []string{"docs: the readme", "perf: faster"}, patchTypes: []string{"fix", "perf"}, expected: "patch"},
+		{name: "no patch types", current: "1.2.3", messages: []string{"chore: deps", "Update the readme"}, patchTypes: []string{"fix", "perf"}},
+		{name: "feature without patch types", current: "1.2.3", messages: []string{"chore: deps", "feat: a flag"}, patchTypes: []string{"fix"}, expected: "minor"},
+	} {
+		current, _ := parseSemver(tc.current)
+		var commits []commit
+		for _, message := range tc.messages {
+			commits = append(commits, commit{Message: message})
+		}
+		if actual := versionBump(current, commits, tc.patchTypes); actual != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+
+	"dagger/mason-git-info/internal/dagger"
+)
+
+// versionInfo is the version of the current commit, computed from the git tags and the conventional commits
+type versionInfo struct {
+	// the latest semver tag reachable from HEAD, if any
+	Tag     string `json:"tag,omitempty"`
+	Current string `json:"current"`
+	// the next release version, bumped from the conventional commits since the tag
+	Next    string `json:"next"`
+	NextTag string `json:"nextTag"`
+	// major, minor or patch - or empty if none of the commits since the tag bumps the version
+	Bump        string `json:"bump,omitempty"`
+	CommitCount int    `json:"commitCount"`
+	ShortSha    string `json:"shortSha"`
+	Dirty       bool   `json:"dirty"`
+	Prerelease  string `json:"prerelease,omitempty"`
+	Build       string `json:"build,omitempty"`
+	// the version of the current commit: the current version if HEAD is a clean release,
+	// or else the next version with the pre-release and build metadata
+	Version string `json:"version"`
+}
+
+// Version returns the semantic version of the current commit:
+// the latest semver tag reachable from HEAD if it points to HEAD and the worktree is clean,
+// or else the next version computed from the conventional commits since the tag,
+// with the commit count as pre-release and the short SHA as build metadata - such as 1.3.0-dev.4+abc1234.dirty.
+func (g *MasonGitInfo) Version(
+	ctx context.Context,
+	// prefix of the tags, such as "api/" for the tags of a monorepo module
+	// +optional
+	tagPrefix string,
+	// identifier of the pre-release versions - the pre-release is only the commit count when empty
+	// +optional
+	// +default="dev"
+	prerelease string,
+	// types of the conventional commits bumping the patch version, such as "fix" and "perf" -
+	// all the commits when empty
+	// +optional
+	patchTypes []string,
+) (string, error) {
+	version, err := g.version(ctx, tagPrefix, prerelease, patchTypes)
+	if err != nil {
+		return "", err
+	}
+	return version.Version, nil
+}
+
+// VersionFile returns the current and next semantic versions, as a JSON file,
+// with the pre-release and build metadata: commit count, short SHA and dirty state.
+func (g *MasonGitInfo) VersionFile(
+	ctx context.Context,
+	// prefix of the tags, such as "api/" for the tags of a monorepo module
+	// +optional
+	tagPrefix string,
+	// identifier of the pre-release versions - the pre-release is only the commit count when empty
+	// +optional
+	// +default="dev"
+	prerelease string,
+	// types of the conventional commits bumping the patch version, such as "fix" and "perf" -
+	// all the commits when empty
+	// +optional
+	patchTypes []string,
+) (*dagger.File, error) {
+	version, err := g.version(ctx, tagPrefix, prerelease, patchTypes)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(version, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return dag.File("version.json", string(data)), nil
+}
+
+func (g *MasonGitInfo) version(ctx context.Context, tagPrefix, prerelease string, patchTypes []string) (*versionInfo, error) {
+	tag, current, err := g.latestVersionTag(ctx, tagPrefix, "--merged", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	revisionRange := "HEAD"
+	if tag != "" {
+		revisionRange = tag + "..HEAD"
+	}
+	commits, err := g.commits(ctx, revisionRange)
+	if err != nil {
+		return nil, err
+	}
+	shortSha, err := g.ShortSha(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dirty, err := g.IsDirty(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bump := versionBump(current, commits, patchTypes)
+	next := current.bump(bump)
+	info := &versionInfo{
+		Tag:         tag,
+		Current:     current.String(),
+		Next:        next.String(),
+		NextTag:     tagPrefix + "v" + next.String(),
+		Bump:        bump,
+		CommitCount: len(commits),
+		ShortSha:    shortSha,
+		Dirty:       dirty,
+	}
+	if tag != "" && !strings.HasPrefix(strings.TrimPrefix(tag, tagPrefix), "v") {
+		info.NextTag = tagPrefix + next.String()
+	}
+
+	if info.CommitCount == 0 && !info.Dirty {
+		info.Version = info.Current
+		return info, nil
+	}
+	version := next
+	if info.CommitCount > 0 {
+		if bump == "" {
+			// none of the commits bumps the version, but their pre-release versions still follow the current one
+			version = current.bump("patch")
+		}
+		// the pre-release versions of the next version have a lower precedence than the release
+		info.Prerelease = strings.TrimPrefix(prerelease+"."+strconv.Itoa(info.CommitCount), ".")
+		version.Prerelease = info.Prerelease
+	}
+	info.Build = shortSha
+	if info.Dirty {
+		info.Build += ".dirty"
+	}
+	version.Build = info.Build
+	info.Version = version.String()
+	return info, nil
+}
+
+// latestVersionTag returns the highest semver tag with the given prefix matching the git tag filters,
+// such as "--merged HEAD", and its version - or 0.0.0 if there are none
+func (g *MasonGitInfo) latestVersionTag(ctx context.Context, tagPrefix string, filters ...string) (string, semver, error) {
+	tags, err := g.Container.WithExec(slices.Concat(
+		[]string{"git", "tag"}, filters, []string{"--list", tagPrefix + "*"},
+	)).Stdout(ctx)
+	if err != nil {
+		return "", semver{}, err
+	}
+	var latestTag string
+	var latest semver
+	for _, tag := range strings.Fields(tags) {
+		version, ok := parseSemver(strings.TrimPrefix(tag, tagPrefix))
+		if !ok || !strings.HasPrefix(tag, tagPrefix) {
+			continue
+		}
+		if latestTag == "" || version.compare(latest) > 0 {
+			latestTag, latest = tag, version
+		}
+	}
+	return latestTag, latest, nil
+}
+
+// versionBump returns the level of the version bump for the given commits:
+// major for the breaking changes - or minor for the 0.x versions -, minor for the features,
+// patch for the commits of the given types - or all the other commits if there are none -, and empty otherwise
+func versionBump(current semver, commits []commit, patchTypes []string) string {
+	bump := ""
+	for _, c := range commits {
+		conventional, _ := parseConventionalCommit(c.Message)
+		switch {
+		case conventional.Breaking && current.Major > 0:
+			return "major"
+		case conventional.Breaking, conventional.Type == "feat":
+			bump = "minor"
+		case bump == "" && (len(patchTypes) == 0 || slices.Contains(patchTypes, conventional.Type)):
+			bump = "patch"
+		}
+	}
+	return bump
+}
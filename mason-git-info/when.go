@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"dagger/mason-git-info/gitrepo"
+	"dagger/mason-git-info/internal/dagger"
+)
+
+// SkipReason checks the git conditions of a brick,
+// and returns the reason why it should be skipped - or an empty string if it should run.
+// The branch and tag patterns are alternatives: the brick runs if either of them matches.
+func (g *MasonGitInfo) SkipReason(
+	ctx context.Context,
+	// glob patterns of the paths - with ** for any number of directories
+	// at least one changed file must match
+	// +optional
+	paths []string,
+	// glob patterns of the branch names
+	// +optional
+	branches []string,
+	// glob patterns of the tag names
+	// +optional
+	tags []string,
+	// git reference the changed files are compared to
+	// +optional
+	// +default="origin"
+	diffTarget string,
+) (string, error) {
+	if len(branches) > 0 || len(tags) > 0 {
+		reason, err := g.refSkipReason(ctx, branches, tags)
+		if err != nil || reason != "" {
+			return reason, err
+		}
+	}
+
+	if len(paths) > 0 {
+		files, err := g.changedPaths(ctx, diffTarget)
+		if err != nil {
+			return "", err
+		}
+		if !slices.ContainsFunc(files, func(file string) bool {
+			return matchAny(paths, file, matchPath)
+		}) {
+			return fmt.Sprintf("no changed file matches %s", strings.Join(paths, ", ")), nil
+		}
+	}
+
+	return "", nil
+}
+
+func (g *MasonGitInfo) refSkipReason(ctx context.Context, branches, tags []string) (string, error) {
+	var refs []string
+	if len(branches) > 0 {
+		branch, err := g.BranchName(ctx)
+		switch {
+		case errors.Is(err, gitrepo.ErrDetachedHead):
+			refs = append(refs, "the detached HEAD")
+		case err != nil:
+			return "", err
+		case matchAny(branches, branch, path.Match):
+			return "", nil
+		default:
+			refs = append(refs, fmt.Sprintf("branch %q", branch))
+		}
+	}
+	if len(tags) > 0 {
+		currentTags, err := g.Container.WithExec([]string{
+			"git", "tag", "--points-at", "HEAD",
+		}).Stdout(ctx)
+		if err != nil {
+			return "", err
+		}
+		for _, tag := range strings.Fields(currentTags) {
+			if matchAny(tags, tag, path.Match) {
+				return "", nil
+			}
+			refs = append(refs, fmt.Sprintf("tag %q", tag))
+		}
+	}
+	if len(refs) == 0 {
+		refs = append(refs, "the current commit")
+	}
+	return fmt.Sprintf("%s doesn't match %s", strings.Join(refs, ", "), strings.Join(slices.Concat(branches, tags), ", ")), nil
+}
+
+// changedPaths returns the sorted paths of the files changed since the given git reference,
+// including the local changes and the untracked files
+func (g *MasonGitInfo) changedPaths(ctx context.Context, diffTarget string) ([]string, error) {
+	if err := g.ensureRef(ctx, diffTarget); err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, args := range [][]string{
+		{"git", "diff", "--name-only", "-z", diffTarget},
+		{"git", "diff", "--name-only", "-z"},
+		{"git", "ls-files", "--others", "--exclude-standard", "-z"},
+	} {
+		output, err := g.Container.WithExec(args, dagger.ContainerWithExecOpts{
+			Expect: dagger.ReturnTypeAny,
+		}).Stdout(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range strings.Split(output, "\x00") {
+			if file != "" {
+				files = append(files, file)
+			}
+		}
+	}
+	slices.Sort(files)
+	return slices.Compact(files), nil
+}
+
+func matchAny(patterns []string, name string, match func(pattern, name string) (bool, error)) bool {
+	for _, pattern := range patterns {
+		if ok, _ := match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath reports whether the given slash-separated path matches the glob pattern,
+// where ** matches any number of directories
+func matchPath(pattern, name string) (bool, error) {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(patterns, names []string) (bool, error) {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			for i := range len(names) + 1 {
+				if ok, err := matchSegments(patterns[1:], names[i:]); ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(names) == 0 {
+			return false, nil
+		}
+		if ok, err := path.Match(patterns[0], names[0]); !ok || err != nil {
+			return false, err
+		}
+		patterns, names = patterns[1:], names[1:]
+	}
+	return len(names) == 0, nil
+}
@@ -5,6 +5,7 @@ go 1.24.3
 require (
 	github.com/99designs/gqlgen v0.17.73
 	github.com/Khan/genqlient v0.8.0
+	github.com/vbehar/mason-modules/brickspec v0.0.0-00010101000000-000000000000
 	github.com/vbehar/mason-sdk-go v0.0.0-20250522190840-129ede87e886
 	github.com/vektah/gqlparser/v2 v2.5.26
 	go.opentelemetry.io/otel v1.34.0
@@ -40,6 +41,7 @@ require (
 	google.golang.org/genproto/googleapis/api v0.0.0-20250218202821-56aae31c358a // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250218202821-56aae31c358a // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 replace go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc => go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc v0.8.0
@@ -49,3 +51,5 @@ replace go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp => go.opente
 replace go.opentelemetry.io/otel/log => go.opentelemetry.io/otel/log v0.8.0
 
 replace go.opentelemetry.io/otel/sdk/log => go.opentelemetry.io/otel/sdk/log v0.8.0
+
+replace github.com/vbehar/mason-modules/brickspec => ../brickspec
@@ -1,45 +1,43 @@
 package main
 
 import (
-	"fmt"
-	"strings"
-
+	"github.com/vbehar/mason-modules/brickspec"
 	"github.com/vbehar/mason-sdk-go"
 )
 
 type LLMCodeReviewSpec struct {
-	LLM                    LLMCodeReviewSpecLLM                  `json:"llm"`
-	Workspace              LLMCodeReviewSpecInputSourceDirectory `json:"workspace"`
-	AdditionalInputs       []LLMCodeReviewSpecInput              `json:"AdditionalInputs"`
-	AdditionalInstructions string                                `json:"AdditionalInstructions"`
-	Output                 LLMCodeReviewSpecOutput               `json:"output"`
+	LLM                    LLMCodeReviewSpecLLM                  `json:"llm" description:"LLM configuration."`
+	Workspace              LLMCodeReviewSpecInputSourceDirectory `json:"workspace" description:"Directory containing the source code of the project."`
+	AdditionalInputs       []LLMCodeReviewSpecInput              `json:"additionalInputs" description:"Additional inputs given to the LLM."`
+	AdditionalInstructions string                                `json:"additionalInstructions" description:"Additional instructions appended to the prompt."`
+	Output                 LLMCodeReviewSpecOutput               `json:"output" validate:"required" description:"Where to write the result."`
 }
 
 type LLMCodeReviewSpecLLM struct {
-	Model       string `json:"model"`
-	MaxAPICalls int    `json:"maxAPICalls"`
+	Model       string `json:"model" description:"LLM model to use."`
+	MaxAPICalls int    `json:"maxAPICalls" description:"Maximum number of API calls to the LLM."`
 }
 
 type LLMCodeReviewSpecInput struct {
-	Name        string                       `json:"name"`
-	Description string                       `json:"description"`
-	Source      LLMCodeReviewSpecInputSource `json:"source"`
+	Name        string                       `json:"name" validate:"required" description:"Name of the input."`
+	Description string                       `json:"description" description:"Description of the input, for the LLM."`
+	Source      LLMCodeReviewSpecInputSource `json:"source" description:"Source of the input."`
 }
 
 type LLMCodeReviewSpecInputSource struct {
-	DaggerFileName string                                `json:"daggerFileName"`
-	Directory      LLMCodeReviewSpecInputSourceDirectory `json:"directory"`
+	DaggerFileName string                                `json:"daggerFileName" validate:"identifier" description:"Name of the dagger variable holding the input file, produced by another brick."`
+	Directory      LLMCodeReviewSpecInputSourceDirectory `json:"directory" description:"Directory on the host."`
 }
 
 type LLMCodeReviewSpecInputSourceDirectory struct {
-	Path    string   `json:"path"`
-	Include []string `json:"include"`
-	Exclude []string `json:"exclude"`
+	Path    string   `json:"path" description:"Path of the directory on the host."`
+	Include []string `json:"include" description:"Patterns of the files to include."`
+	Exclude []string `json:"exclude" description:"Patterns of the files to exclude."`
 }
 
 type LLMCodeReviewSpecOutput struct {
-	DaggerFileName string `json:"daggerFileName"`
-	HostFilePath   string `json:"hostFilePath"`
+	DaggerFileName string `json:"daggerFileName" validate:"required,identifier" description:"Name of the dagger variable holding the result file, for use by other bricks."`
+	HostFilePath   string `json:"hostFilePath" description:"Path on the host where to write the result file."`
 }
 
 func (s LLMCodeReviewSpec) Plan(brick mason.Brick) map[string]string {
@@ -52,57 +50,69 @@ func (s LLMCodeReviewSpec) Plan(brick mason.Brick) map[string]string {
 	return plan
 }
 
-func (s LLMCodeReviewSpec) script(brick mason.Brick) string {
-	brickName := strings.ReplaceAll(brick.Metadata.Name, "-", "_")
-
-	workspace := "host | directory "
-	if s.Workspace.Path != "" {
-		workspace += s.Workspace.Path
-	} else {
-		workspace += "."
+func (s LLMCodeReviewSpec) Artifacts() brickspec.Artifacts {
+	artifacts := brickspec.Artifacts{
+		Inputs:   []string{workspacePath(s.Workspace.Path)},
+		Outputs:  []string{s.Output.HostFilePath},
+		Produces: []string{s.Output.DaggerFileName},
 	}
-	if len(s.Workspace.Include) > 0 {
-		workspace += ` --include "` + strings.Join(s.Workspace.Include, `","`) + `"`
-	}
-	if len(s.Workspace.Exclude) > 0 {
-		workspace += ` --exclude "` + strings.Join(s.Workspace.Exclude, `","`) + `"`
+	for _, input := range s.AdditionalInputs {
+		switch {
+		case input.Source.DaggerFileName != "":
+			artifacts.Consumes = append(artifacts.Consumes, input.Source.DaggerFileName)
+		case input.Source.Directory.Path != "":
+			artifacts.Inputs = append(artifacts.Inputs, input.Source.Directory.Path)
+		}
 	}
+	return artifacts
+}
+
+func (s LLMCodeReviewSpec) script(brick mason.Brick) string {
+	reviewVar := brick.Metadata.Name + "_code_review"
 
-	env := "env"
+	env := brickspec.Command("env")
 	for _, input := range s.AdditionalInputs {
 		switch {
 		case input.Source.DaggerFileName != "":
-			env += fmt.Sprintf(" | \n with-file-input %q $%s %q", input.Name, input.Source.DaggerFileName, input.Description)
+			env.Pipe("with-file-input", input.Name).
+				Ref(brickspec.Var(input.Source.DaggerFileName)).
+				Arg(input.Description)
 		case input.Source.Directory.Path != "":
-			dir := fmt.Sprintf("host | directory %s", input.Source.Directory.Path)
-			if len(input.Source.Directory.Include) > 0 {
-				dir += ` --include "` + strings.Join(input.Source.Directory.Include, `","`) + `"`
-			}
-			if len(input.Source.Directory.Exclude) > 0 {
-				dir += ` --exclude "` + strings.Join(input.Source.Directory.Exclude, `","`) + `"`
-			}
-			env += fmt.Sprintf(" | \n with-directory-input %q $( %s ) %q", input.Name, dir, input.Description)
+			dir := input.Source.Directory
+			env.Pipe("with-directory-input", input.Name).
+				Ref(brickspec.Sub(brickspec.HostDirectory(dir.Path, dir.Include, dir.Exclude))).
+				Arg(input.Description)
 		}
 	}
 
-	llm := "llm"
-	if s.LLM.Model != "" {
-		llm += fmt.Sprintf(" --model %s", s.LLM.Model)
-	}
-	if s.LLM.MaxAPICalls > 0 {
-		llm += fmt.Sprintf(" --max-api-calls %d", s.LLM.MaxAPICalls)
+	llm := brickspec.Command("llm").
+		Flag("model", s.LLM.Model).
+		IntFlag("max-api-calls", s.LLM.MaxAPICalls)
+
+	var script brickspec.Script
+	script.Assign(reviewVar, brickspec.Command(brick.ModuleRef).
+		Pipe("review-code").
+		Ref(brickspec.Sub(brickspec.HostDirectory(s.Workspace.Path, s.Workspace.Include, s.Workspace.Exclude))).
+		RefFlag("env", brickspec.Sub(env)).
+		RefFlag("llm", brickspec.Sub(llm)).
+		Flag("additional-instructions", s.AdditionalInstructions))
+	script.Run(brickspec.FromRef(brickspec.Var(reviewVar)).Pipe("provider-info"))
+	script.Echo("")
+	script.Run(brickspec.FromRef(brickspec.Var(reviewVar)).Pipe("tokens-info"))
+	script.Echo("")
+	script.Assign(s.Output.DaggerFileName, brickspec.FromRef(brickspec.Var(reviewVar)).Pipe("result-file"))
+	if s.Output.HostFilePath != "" {
+		script.Echo("Local output file: ")
+		script.Run(brickspec.FromRef(brickspec.Var(s.Output.DaggerFileName)).Pipe("export", s.Output.HostFilePath))
 	}
 
-	baseCmd := brick.ModuleRef + " | review-code $(" + workspace + ") --env $(" + env + ") --llm $(" + llm + ") --additional-instructions \"" + s.AdditionalInstructions + "\""
+	return script.String()
+}
 
-	var cmd string
-	cmd += fmt.Sprintf("%s_code_review=$( %s )\n", brickName, baseCmd)
-	cmd += fmt.Sprintf("$%s_code_review | provider-info\n.echo\n", brickName)
-	cmd += fmt.Sprintf("$%s_code_review | tokens-info\n.echo\n", brickName)
-	cmd += fmt.Sprintf("%s=$( $%s_code_review | result-file )\n", s.Output.DaggerFileName, brickName)
-	if s.Output.HostFilePath != "" {
-		cmd += fmt.Sprintf(".echo -n \"Local output file: \"\n$%s | export %s\n", s.Output.DaggerFileName, s.Output.HostFilePath)
+// workspacePath returns the path of the workspace on the host - defaulting to the current directory
+func workspacePath(path string) string {
+	if path == "" {
+		return "."
 	}
-
-	return cmd
+	return path
 }
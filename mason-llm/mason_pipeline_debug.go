@@ -1,47 +1,45 @@
 package main
 
 import (
-	"fmt"
-	"strings"
-
+	"github.com/vbehar/mason-modules/brickspec"
 	"github.com/vbehar/mason-sdk-go"
 )
 
 type LLMPipelineDebugSpec struct {
-	LLM                    LLMPipelineDebugSpecLLM                  `json:"llm"`
-	Workspace              LLMPipelineDebugSpecInputSourceDirectory `json:"workspace"`
-	LogFilePath            string                                   `json:"logFilePath"`
-	AdditionalInputs       []LLMPipelineDebugSpecInput              `json:"additionalInputs"`
-	AdditionalInstructions string                                   `json:"additionalInstructions"`
-	Output                 LLMPipelineDebugSpecOutput               `json:"output"`
+	LLM                    LLMPipelineDebugSpecLLM                  `json:"llm" description:"LLM configuration."`
+	Workspace              LLMPipelineDebugSpecInputSourceDirectory `json:"workspace" description:"Directory containing the source code of the project."`
+	LogFilePath            string                                   `json:"logFilePath" description:"Path of the CI run output log file in the workspace. Defaults to $log_file_path in a post-run brick."`
+	AdditionalInputs       []LLMPipelineDebugSpecInput              `json:"additionalInputs" description:"Additional inputs given to the LLM."`
+	AdditionalInstructions string                                   `json:"additionalInstructions" description:"Additional instructions appended to the prompt."`
+	Output                 LLMPipelineDebugSpecOutput               `json:"output" validate:"required" description:"Where to write the result."`
 }
 
 type LLMPipelineDebugSpecLLM struct {
-	Model       string `json:"model"`
-	MaxAPICalls int    `json:"maxAPICalls"`
+	Model       string `json:"model" description:"LLM model to use."`
+	MaxAPICalls int    `json:"maxAPICalls" description:"Maximum number of API calls to the LLM."`
 }
 
 type LLMPipelineDebugSpecInput struct {
-	Name        string                          `json:"name"`
-	Description string                          `json:"description"`
-	Source      LLMPipelineDebugSpecInputSource `json:"source"`
+	Name        string                          `json:"name" validate:"required" description:"Name of the input."`
+	Description string                          `json:"description" description:"Description of the input, for the LLM."`
+	Source      LLMPipelineDebugSpecInputSource `json:"source" description:"Source of the input."`
 }
 
 type LLMPipelineDebugSpecInputSource struct {
-	DaggerFileName string                                   `json:"daggerFileName"`
-	HostFilePath   string                                   `json:"hostFilePath"`
-	Directory      LLMPipelineDebugSpecInputSourceDirectory `json:"directory"`
+	DaggerFileName string                                   `json:"daggerFileName" validate:"identifier" description:"Name of the dagger variable holding the input file, produced by another brick."`
+	HostFilePath   string                                   `json:"hostFilePath" description:"Path of the input file on the host."`
+	Directory      LLMPipelineDebugSpecInputSourceDirectory `json:"directory" description:"Directory on the host."`
 }
 
 type LLMPipelineDebugSpecInputSourceDirectory struct {
-	Path    string   `json:"path"`
-	Include []string `json:"include"`
-	Exclude []string `json:"exclude"`
+	Path    string   `json:"path" description:"Path of the directory on the host."`
+	Include []string `json:"include" description:"Patterns of the files to include."`
+	Exclude []string `json:"exclude" description:"Patterns of the files to exclude."`
 }
 
 type LLMPipelineDebugSpecOutput struct {
-	DaggerFileName string `json:"daggerFileName"`
-	HostFilePath   string `json:"hostFilePath"`
+	DaggerFileName string `json:"daggerFileName" validate:"required,identifier" description:"Name of the dagger variable holding the result file, for use by other bricks."`
+	HostFilePath   string `json:"hostFilePath" description:"Path on the host where to write the result file."`
 }
 
 func (s LLMPipelineDebugSpec) Plan(brick mason.Brick) map[string]string {
@@ -54,63 +52,73 @@ func (s LLMPipelineDebugSpec) Plan(brick mason.Brick) map[string]string {
 	return plan
 }
 
+func (s LLMPipelineDebugSpec) Artifacts() brickspec.Artifacts {
+	artifacts := brickspec.Artifacts{
+		Inputs:   []string{workspacePath(s.Workspace.Path)},
+		Outputs:  []string{s.Output.HostFilePath},
+		Produces: []string{s.Output.DaggerFileName},
+	}
+	for _, input := range s.AdditionalInputs {
+		switch {
+		case input.Source.DaggerFileName != "":
+			artifacts.Consumes = append(artifacts.Consumes, input.Source.DaggerFileName)
+		case input.Source.HostFilePath != "":
+			artifacts.Inputs = append(artifacts.Inputs, input.Source.HostFilePath)
+		case input.Source.Directory.Path != "":
+			artifacts.Inputs = append(artifacts.Inputs, input.Source.Directory.Path)
+		}
+	}
+	return artifacts
+}
+
 func (s LLMPipelineDebugSpec) script(brick mason.Brick) string {
-	brickName := strings.ReplaceAll(brick.Metadata.Name, "-", "_")
+	debugVar := brick.Metadata.Name + "_pipeline_debug"
 
+	logFilePath := brickspec.Quote(s.LogFilePath)
 	if brick.Metadata.PostRun != "" && s.LogFilePath == "" {
-		s.LogFilePath = "$log_file_path"
-	}
-
-	workspace := "host | directory "
-	if s.Workspace.Path != "" {
-		workspace += s.Workspace.Path
-	} else {
-		workspace += "."
-	}
-	if len(s.Workspace.Include) > 0 {
-		workspace += ` --include "` + strings.Join(s.Workspace.Include, `","`) + `"`
-	}
-	if len(s.Workspace.Exclude) > 0 {
-		workspace += ` --exclude "` + strings.Join(s.Workspace.Exclude, `","`) + `"`
+		logFilePath = brickspec.Var("log_file_path")
 	}
 
-	env := "env"
+	env := brickspec.Command("env")
 	for _, input := range s.AdditionalInputs {
 		switch {
 		case input.Source.DaggerFileName != "":
-			env += fmt.Sprintf(" | \n with-file-input %q $%s %q", input.Name, input.Source.DaggerFileName, input.Description)
+			env.Pipe("with-file-input", input.Name).
+				Ref(brickspec.Var(input.Source.DaggerFileName)).
+				Arg(input.Description)
 		case input.Source.HostFilePath != "":
-			env += fmt.Sprintf(" | \n with-file-input %q $(host | file %s) %q", input.Name, input.Source.HostFilePath, input.Description)
+			env.Pipe("with-file-input", input.Name).
+				Ref(brickspec.Sub(brickspec.HostFile(input.Source.HostFilePath))).
+				Arg(input.Description)
 		case input.Source.Directory.Path != "":
-			dir := fmt.Sprintf("host | directory %s", input.Source.Directory.Path)
-			if len(input.Source.Directory.Include) > 0 {
-				dir += ` --include "` + strings.Join(input.Source.Directory.Include, `","`) + `"`
-			}
-			if len(input.Source.Directory.Exclude) > 0 {
-				dir += ` --exclude "` + strings.Join(input.Source.Directory.Exclude, `","`) + `"`
-			}
-			env += fmt.Sprintf(" | \n with-directory-input %q $( %s ) %q", input.Name, dir, input.Description)
+			dir := input.Source.Directory
+			env.Pipe("with-directory-input", input.Name).
+				Ref(brickspec.Sub(brickspec.HostDirectory(dir.Path, dir.Include, dir.Exclude))).
+				Arg(input.Description)
 		}
 	}
 
-	llm := "llm"
-	if s.LLM.Model != "" {
-		llm += fmt.Sprintf(" --model %s", s.LLM.Model)
-	}
-	if s.LLM.MaxAPICalls > 0 {
-		llm += fmt.Sprintf(" --max-api-calls %d", s.LLM.MaxAPICalls)
-	}
-
-	baseCmd := brick.ModuleRef + " | debug-pipeline $(" + workspace + ") " + s.LogFilePath + " --env $(" + env + ") --llm $(" + llm + ") --additional-instructions \"" + s.AdditionalInstructions + "\""
-
-	var cmd string
-	cmd += fmt.Sprintf("%s_pipeline_debug=$( %s )\n", brickName, baseCmd)
-	cmd += fmt.Sprintf("$%s_pipeline_debug | provider-info\n.echo\n", brickName)
-	cmd += fmt.Sprintf("$%s_pipeline_debug | tokens-info\n.echo\n", brickName)
-	cmd += fmt.Sprintf("%s=$( $%s_pipeline_debug | result-file )\n", s.Output.DaggerFileName, brickName)
+	llm := brickspec.Command("llm").
+		Flag("model", s.LLM.Model).
+		IntFlag("max-api-calls", s.LLM.MaxAPICalls)
+
+	var script brickspec.Script
+	script.Assign(debugVar, brickspec.Command(brick.ModuleRef).
+		Pipe("debug-pipeline").
+		Ref(brickspec.Sub(brickspec.HostDirectory(s.Workspace.Path, s.Workspace.Include, s.Workspace.Exclude))).
+		Ref(logFilePath).
+		RefFlag("env", brickspec.Sub(env)).
+		RefFlag("llm", brickspec.Sub(llm)).
+		Flag("additional-instructions", s.AdditionalInstructions))
+	script.Run(brickspec.FromRef(brickspec.Var(debugVar)).Pipe("provider-info"))
+	script.Echo("")
+	script.Run(brickspec.FromRef(brickspec.Var(debugVar)).Pipe("tokens-info"))
+	script.Echo("")
+	script.Assign(s.Output.DaggerFileName, brickspec.FromRef(brickspec.Var(debugVar)).Pipe("result-file"))
 	if s.Output.HostFilePath != "" {
-		cmd += fmt.Sprintf(".echo -n \"Pipeline debug analysis: \"\n$%s | export %s\n", s.Output.DaggerFileName, s.Output.HostFilePath)
+		script.Echo("Pipeline debug analysis: ")
+		script.Run(brickspec.FromRef(brickspec.Var(s.Output.DaggerFileName)).Pipe("export", s.Output.HostFilePath))
 	}
 
-	return cmd
+	return script.String()
 }
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vbehar/mason-modules/brickspec/plantest"
+)
+
+func TestRenderPlan(t *testing.T) {
+	plantest.Run(t, "testdata/plans", bricks)
+}
@@ -2,25 +2,37 @@ package main
 
 import (
 	"dagger/run/internal/dagger"
-	"strings"
 
+	"github.com/vbehar/mason-modules/brickspec"
 	"github.com/vbehar/mason-sdk-go"
 )
 
+const (
+	goCoverDirPath = "/tmp/gocoverdir"
+)
+
 type RunBinarySpec struct {
-	Platform  dagger.Platform  `json:"platform"`
-	BaseImage string           `json:"baseImage"`
-	Binaries  []RunBinaryEntry `json:"binaries"`
-	Command   []string         `json:"command"`
+	Platform  dagger.Platform       `json:"platform" description:"Platform of the container, such as linux/amd64."`
+	BaseImage string                `json:"baseImage" validate:"required" description:"Base image of the container."`
+	Binaries  []RunBinaryEntry      `json:"binaries" description:"Binaries to add to the container."`
+	Command   []string              `json:"command" validate:"required" description:"Command to run."`
+	Coverage  RunBinarySpecCoverage `json:"coverage" description:"Collect the coverage data written by instrumented binaries."`
 }
 
 type RunBinaryEntry struct {
-	Source RunBinarySource `json:"source"`
-	Path   string          `json:"path"`
+	Source RunBinarySource `json:"source" validate:"required" description:"Source of the binary."`
+	Path   string          `json:"path" validate:"required" description:"Path of the binary in the container."`
 }
 
 type RunBinarySource struct {
-	DaggerFileName string `json:"daggerFileName"`
+	DaggerFileName string `json:"daggerFileName" validate:"required,identifier" description:"Name of the dagger variable holding the binary, produced by another brick."`
+}
+
+// RunBinarySpecCoverage collects the GOCOVERDIR directory
+// written by binaries built with coverage instrumentation
+type RunBinarySpecCoverage struct {
+	DaggerDirectoryName string `json:"daggerDirectoryName" validate:"identifier" description:"Name of the dagger variable holding the GOCOVERDIR directory, for use by other bricks."`
+	HostDirectoryPath   string `json:"hostDirectoryPath" description:"Path on the host where to write the GOCOVERDIR directory."`
 }
 
 func (s RunBinarySpec) Plan(brick mason.Brick) map[string]string {
@@ -33,19 +45,49 @@ func (s RunBinarySpec) Plan(brick mason.Brick) map[string]string {
 	return plan
 }
 
-func (s RunBinarySpec) runScript(_ mason.Brick) string {
-	cmd := "container"
-	if s.Platform != "" {
-		cmd += " --platform " + string(s.Platform)
+func (s RunBinarySpec) Artifacts() brickspec.Artifacts {
+	artifacts := brickspec.Artifacts{
+		Outputs:  []string{s.Coverage.HostDirectoryPath},
+		Produces: []string{s.Coverage.DaggerDirectoryName},
 	}
-	cmd += " | from " + s.BaseImage
 	for _, binary := range s.Binaries {
-		daggerFileName := binary.Source.DaggerFileName
-		if daggerFileName != "" {
-			cmd += " | with-file " + binary.Path + " $" + daggerFileName
-		}
-	}
-	cmd += " | with-exec " + strings.Join(s.Command, " ")
-	cmd += " | stdout"
-	return cmd
+		artifacts.Consumes = append(artifacts.Consumes, binary.Source.DaggerFileName)
+	}
+	return artifacts
+}
+
+func (s RunBinarySpec) runScript(brick mason.Brick) string {
+	cmd := brickspec.Command("container").
+		Flag("platform", string(s.Platform)).
+		Pipe("from", s.BaseImage)
+	for _, binary := range s.Binaries {
+		cmd.Pipe("with-file", binary.Path).Ref(brickspec.Var(binary.Source.DaggerFileName))
+	}
+
+	var script brickspec.Script
+	if !s.Coverage.enabled() {
+		script.Run(cmd.Pipe("with-exec", s.Command...).Pipe("stdout"))
+		return script.String()
+	}
+
+	runVar := brick.Metadata.Name + "_run"
+	cmd.Pipe("with-directory", goCoverDirPath).Ref(brickspec.Sub(brickspec.Command("directory"))).
+		Pipe("with-env-variable", "GOCOVERDIR", goCoverDirPath).
+		Pipe("with-exec", s.Command...)
+	script.Assign(runVar, cmd)
+	script.Run(brickspec.FromRef(brickspec.Var(runVar)).Pipe("stdout"))
+
+	coverDir := brickspec.FromRef(brickspec.Var(runVar)).Pipe("directory", goCoverDirPath)
+	if s.Coverage.DaggerDirectoryName != "" {
+		script.Assign(s.Coverage.DaggerDirectoryName, coverDir)
+		coverDir = brickspec.FromRef(brickspec.Var(s.Coverage.DaggerDirectoryName))
+	}
+	if s.Coverage.HostDirectoryPath != "" {
+		script.Run(coverDir.Pipe("export", s.Coverage.HostDirectoryPath))
+	}
+	return script.String()
+}
+
+func (c RunBinarySpecCoverage) enabled() bool {
+	return c.DaggerDirectoryName != "" || c.HostDirectoryPath != ""
 }